@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-ex/definition"
+)
+
+// A graph is the event trigger graph of an exercise definition.
+type graph struct {
+	nodes  []*node
+	edges  []*edge
+	groups []string
+}
+
+// A node is an event in the graph, or one of the pseudo-events "start",
+// "manual", and "at TIME".
+type node struct {
+	id     string
+	label  string
+	group  string
+	pseudo bool
+}
+
+// An edge is a trigger relationship between two nodes.  Its label gives the
+// delay and condition, if any, whether the trigger is on the source event
+// becoming overdue, and whether the event needs all or any of its triggers if
+// it has several.
+type edge struct {
+	from, to *node
+	label    string
+}
+
+// buildGraph builds the event trigger graph for the definition.
+func buildGraph(def *definition.Definition) (g *graph) {
+	var (
+		byEvent = make(map[*definition.Event]*node)
+		byTime  = make(map[string]*node)
+		start   = &node{id: "start", label: "start", pseudo: true}
+		manual  = &node{id: "manual", label: "manual", pseudo: true}
+	)
+	g = new(graph)
+	g.nodes = append(g.nodes, start, manual)
+	for i, e := range def.Events {
+		n := &node{id: fmt.Sprintf("e%d", i+1), label: fmt.Sprintf("%s %s", e.Type, e.Name), group: e.Group}
+		if e.Repeat != nil {
+			n.label += fmt.Sprintf(" (every %s)", formatDelay(e.Repeat.Interval))
+		}
+		byEvent[e] = n
+		g.nodes = append(g.nodes, n)
+		if e.Group != "" && !slices.Contains(g.groups, e.Group) {
+			g.groups = append(g.groups, e.Group)
+		}
+	}
+	for _, e := range def.Events {
+		var from *node
+		switch e.TriggerType {
+		case definition.EventStart:
+			from = start
+		case definition.EventManual:
+			from = manual
+		case definition.EventAt:
+			// Each trigger time gets its own pseudo-node.
+			if from = byTime[e.TriggerName]; from == nil {
+				from = &node{id: fmt.Sprintf("at%d", len(byTime)+1), label: "at " + e.TriggerName, pseudo: true}
+				byTime[e.TriggerName] = from
+				g.nodes = append(g.nodes, from)
+			}
+		default:
+			from = byEvent[def.Event(e.TriggerType, e.TriggerName)]
+		}
+		var label []string
+		if e.TriggerOverdue {
+			label = append(label, "overdue")
+		}
+		if from := e.TriggerFrom(); from != "" {
+			label = append(label, "from "+from)
+		}
+		if e.Triggers != nil && e.AllTriggers {
+			label = append(label, "all of")
+		} else if e.Triggers != nil {
+			label = append(label, "any of")
+		}
+		if e.DelayMax != 0 {
+			label = append(label, formatDelay(e.Delay)+"-"+formatDelay(e.DelayMax))
+		} else if e.Delay != 0 {
+			label = append(label, formatDelay(e.Delay))
+		}
+		if e.Condition != nil {
+			label = append(label, e.Condition.String())
+		}
+		g.edges = append(g.edges, &edge{from: from, to: byEvent[e], label: strings.Join(label, "; ")})
+		// An event with multiple triggers has an arrow from each of them.
+		if e.Triggers != nil {
+			for _, t := range e.Triggers[1:] {
+				g.edges = append(g.edges, &edge{from: byEvent[def.Event(t.Type, t.Name)], to: byEvent[e], label: strings.Join(label, "; ")})
+			}
+		}
+	}
+	return g
+}
+
+// formatDelay formats a delay duration without the trailing zero units that
+// time.Duration.String adds.
+func formatDelay(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// writeDOT writes the graph in Graphviz DOT format.
+func writeDOT(w io.Writer, g *graph) {
+	var quote = func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	var writeNode = func(indent string, n *node) {
+		if n.pseudo {
+			fmt.Fprintf(w, "%s%s [label=%s, shape=ellipse];\n", indent, n.id, quote(n.label))
+		} else {
+			fmt.Fprintf(w, "%s%s [label=%s];\n", indent, n.id, quote(n.label))
+		}
+	}
+	fmt.Fprintln(w, "digraph events {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, n := range g.nodes {
+		if n.group == "" {
+			writeNode("  ", n)
+		}
+	}
+	for i, group := range g.groups {
+		fmt.Fprintf(w, "  subgraph cluster_%d {\n    label=%s;\n", i+1, quote(group))
+		for _, n := range g.nodes {
+			if n.group == group {
+				writeNode("    ", n)
+			}
+		}
+		fmt.Fprintln(w, "  }")
+	}
+	for _, e := range g.edges {
+		if e.label != "" {
+			fmt.Fprintf(w, "  %s -> %s [label=%s];\n", e.from.id, e.to.id, quote(e.label))
+		} else {
+			fmt.Fprintf(w, "  %s -> %s;\n", e.from.id, e.to.id)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeMermaid writes the graph in Mermaid flowchart format.
+func writeMermaid(w io.Writer, g *graph) {
+	var quote = func(s string) string {
+		return `"` + strings.NewReplacer(`"`, "#quot;").Replace(s) + `"`
+	}
+	var writeNode = func(indent string, n *node) {
+		if n.pseudo {
+			fmt.Fprintf(w, "%s%s([%s])\n", indent, n.id, quote(n.label))
+		} else {
+			fmt.Fprintf(w, "%s%s[%s]\n", indent, n.id, quote(n.label))
+		}
+	}
+	fmt.Fprintln(w, "flowchart LR")
+	for _, n := range g.nodes {
+		if n.group == "" {
+			writeNode("  ", n)
+		}
+	}
+	for i, group := range g.groups {
+		fmt.Fprintf(w, "  subgraph g%d [%s]\n", i+1, quote(group))
+		for _, n := range g.nodes {
+			if n.group == group {
+				writeNode("    ", n)
+			}
+		}
+		fmt.Fprintln(w, "  end")
+	}
+	for _, e := range g.edges {
+		if e.label != "" {
+			fmt.Fprintf(w, "  %s -- %s --> %s\n", e.from.id, quote(e.label), e.to.id)
+		} else {
+			fmt.Fprintf(w, "  %s --> %s\n", e.from.id, e.to.id)
+		}
+	}
+}
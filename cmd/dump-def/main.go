@@ -1,6 +1,10 @@
+// dump-def reads an exercise definition and dumps its parsed form.  With -dot
+// or -mermaid, it instead renders the event trigger graph in Graphviz DOT or
+// Mermaid flowchart format, for reviewing the exercise flow visually.
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -10,14 +14,30 @@ import (
 )
 
 func main() {
-	var fname = "exercise.def"
-	if len(os.Args) > 1 {
-		fname = os.Args[1]
+	var (
+		fname   = "exercise.def"
+		dot     = flag.Bool("dot", false, "render the event trigger graph in Graphviz DOT format")
+		mermaid = flag.Bool("mermaid", false, "render the event trigger graph in Mermaid format")
+	)
+	flag.Parse()
+	if flag.NArg() > 1 || (*dot && *mermaid) {
+		fmt.Fprintln(os.Stderr, "usage: dump-def [-dot | -mermaid] [definition-file]")
+		os.Exit(2)
+	}
+	if flag.NArg() == 1 {
+		fname = flag.Arg(0)
 	}
 	xscmsg.Register()
 	def, err := definition.Read(fname)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 	}
-	spew.Dump(def)
+	switch {
+	case def != nil && *dot:
+		writeDOT(os.Stdout, buildGraph(def))
+	case def != nil && *mermaid:
+		writeMermaid(os.Stdout, buildGraph(def))
+	default:
+		spew.Dump(def)
+	}
 }
@@ -0,0 +1,53 @@
+// fake-bbs runs a local stand-in for the BBS named in an exercise definition,
+// so that the exercise can be run end to end without a radio or a real BBS.
+// It listens on the definition's bbsaddress (or the address given with -addr)
+// and requires the definition's bbspassword for the exercise's own call sign;
+// any other call sign can log in with any password.  Participants can telnet
+// to it and send messages with the SP command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet-ex/fakebbs"
+	"github.com/rothskeller/packet/xscmsg"
+)
+
+func main() {
+	var (
+		fname string
+		def   *definition.Definition
+		bbs   *fakebbs.BBS
+		err   error
+		addr  = flag.String("addr", "", "listen address (default: bbsaddress from definition)")
+	)
+	flag.Parse()
+	switch len(flag.Args()) {
+	case 0:
+		fname = "exercise.def"
+	case 1:
+		fname = flag.Arg(0)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: fake-bbs [-addr host:port] [definition-file]")
+		os.Exit(2)
+	}
+	xscmsg.Register()
+	if def, err = definition.Read(fname); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	if *addr == "" {
+		*addr = def.Exercise.BBSAddress
+	}
+	bbs = fakebbs.New(def.Exercise.BBSName)
+	bbs.Passwords = map[string]string{strings.ToLower(def.Exercise.MyCall): def.Exercise.BBSPassword}
+	fmt.Printf("fake %s listening on %s\n", bbs.Name, *addr)
+	if err = bbs.ListenAndServe(*addr); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+}
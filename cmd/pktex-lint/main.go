@@ -0,0 +1,47 @@
+// pktex-lint checks an exercise definition file.  It reports all of the errors
+// in the file, not just the first one.  If there are no errors, it goes on to
+// report warnings about things that are legal but probably mistakes: events
+// that can never be triggered, RECEIVE sections that can never be matched,
+// condition variables that are never set, react durations shorter than delays,
+// and inject messages that fail PackItForms validation.  It exits with status 1
+// if there are errors, and with status 0 otherwise.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet-ex/engine"
+	"github.com/rothskeller/packet/xscmsg"
+)
+
+func main() {
+	var (
+		fname = "exercise.def"
+		def   *definition.Definition
+		errs  []error
+	)
+	switch len(os.Args) {
+	case 1:
+		break
+	case 2:
+		fname = os.Args[1]
+	default:
+		fmt.Fprintln(os.Stderr, "usage: pktex-lint [definition-file]")
+		os.Exit(2)
+	}
+	xscmsg.Register()
+	if def, errs = definition.ReadAll(fname); len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Printf("ERROR: %s\n", err)
+		}
+		os.Exit(1)
+	}
+	for _, w := range def.Lint() {
+		fmt.Printf("WARNING: %s: %s\n", fname, w)
+	}
+	for _, w := range engine.LintTemplates(def) {
+		fmt.Printf("WARNING: %s: %s\n", fname, w)
+	}
+}
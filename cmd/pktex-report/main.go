@@ -111,35 +111,34 @@ func genGroupReport(fh io.Writer, def *definition.Definition, st *state.State, s
 		if edef.Group != group {
 			continue
 		}
-		ev := st.FindEvent(edef.Type, stn.CallSign, edef.Name)
-		if ev == nil {
-			continue
-		}
-		if !started {
-			if group == "" {
-				io.WriteString(fh, "\n<h2>Other Events</h2>\n")
-			} else {
-				fmt.Fprintf(fh, "\n<h2>%s Events</h2>\n", html.EscapeString(group))
+		// A repeating event is reported once for each instance.
+		for _, ev := range st.FindEvents(edef.Type, stn.CallSign, edef.Name) {
+			if !started {
+				if group == "" {
+					io.WriteString(fh, "\n<h2>Other Events</h2>\n")
+				} else {
+					fmt.Fprintf(fh, "\n<h2>%s Events</h2>\n", html.EscapeString(group))
+				}
+				started = true
+			}
+			switch edef.Type {
+			case definition.EventAlert:
+				genAlertReport(fh, def, edef, ev, stn)
+			case definition.EventBulletin:
+				genBulletinReport(fh, def, edef, ev, stn)
+			case definition.EventDeliver:
+				genDeliverReport(fh, def, edef, ev, stn)
+			case definition.EventInject:
+				genInjectReport(fh, def, edef, ev, stn)
+			case definition.EventReceipt:
+				genReceiptReport(fh, def, edef, ev, stn)
+			case definition.EventReceive:
+				genReceiveReport(fh, def, edef, ev, stn)
+			case definition.EventReject:
+				genRejectReport(fh, def, edef, ev, stn)
+			case definition.EventSend:
+				genSendReport(fh, def, edef, ev, stn)
 			}
-			started = true
-		}
-		switch edef.Type {
-		case definition.EventAlert:
-			genAlertReport(fh, def, edef, ev, stn)
-		case definition.EventBulletin:
-			genBulletinReport(fh, def, edef, ev, stn)
-		case definition.EventDeliver:
-			genDeliverReport(fh, def, edef, ev, stn)
-		case definition.EventInject:
-			genInjectReport(fh, def, edef, ev, stn)
-		case definition.EventReceipt:
-			genReceiptReport(fh, def, edef, ev, stn)
-		case definition.EventReceive:
-			genReceiveReport(fh, def, edef, ev, stn)
-		case definition.EventReject:
-			genRejectReport(fh, def, edef, ev, stn)
-		case definition.EventSend:
-			genSendReport(fh, def, edef, ev, stn)
 		}
 	}
 }
@@ -199,15 +198,25 @@ func genReceiveReport(fh io.Writer, def *definition.Definition, edef *definition
 	if !ev.Expected().IsZero() && ev.Occurred().After(ev.Expected()) {
 		fmt.Fprintf(fh, "  This was %s later than expected.", formatDuration(ev.Occurred().Sub(ev.Expected())))
 	}
-	if ev.Score() != 0 {
+	if attempts := ev.Attempts(); len(attempts) > 1 {
+		fmt.Fprintf(fh, "  %s sent the message %d times, the last copy arriving at %s.",
+			stn.CallSign, len(attempts), formatDateTime(def, attempts[len(attempts)-1].Received))
+		if ev.Score() != 0 {
+			fmt.Fprintf(fh, "  The last copy had a transcription score of %d%%.", ev.Score())
+		}
+	} else if ev.Score() != 0 {
 		fmt.Fprintf(fh, "  The message had a transcription score of %d%%.", ev.Score())
 	}
 	io.WriteString(fh, "</p>\n")
 }
 
 func genRejectReport(fh io.Writer, def *definition.Definition, _ *definition.Event, ev *state.Event, stn *definition.Station) {
-	fmt.Fprintf(fh, "<p>At %s, a message from %s was rejected by the automation.  It could not be matched to any expected message.</p>\n",
+	fmt.Fprintf(fh, "<p>At %s, a message from %s was rejected by the automation.  It could not be matched to any expected message.",
 		formatDateTime(def, ev.Occurred()), stn.CallSign)
+	if tostn, toname := ev.ReassignedTo(); tostn != "" {
+		fmt.Fprintf(fh, "  The exercise manager later reassigned it to %s from %s.", html.EscapeString(toname), tostn)
+	}
+	io.WriteString(fh, "</p>\n")
 }
 
 func genSendReport(fh io.Writer, def *definition.Definition, edef *definition.Event, ev *state.Event, stn *definition.Station) {
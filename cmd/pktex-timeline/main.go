@@ -0,0 +1,336 @@
+// pktex-timeline prints a preview of when things will happen in an exercise.
+// It walks through the events in the exercise definition, starting at opstart,
+// assuming that every expected event (receive, deliver, alert) occurs at its
+// expected time, or at the fraction of its delay given with -react.  It prints
+// a timeline of the resulting sends, injects, bulletins, and expected events,
+// for the exercise as a whole and then for each station.
+//
+// usage: pktex-timeline [-react fraction] [definition-file]
+//
+// Event conditions are evaluated against the exercise and station variables.
+// Conditions on variables from messages, or on variables changed by set
+// events, can't be evaluated in advance; they are assumed to be met, and are
+// flagged in the timeline.  Events whose
+// conditions are not met are listed as skipped.  Events with manual triggers
+// don't appear in the timeline, but are listed at the end.  Repeating events
+// are shown once for each instance.  Since all stations are assumed to react
+// in lockstep, quorum triggers are met by the stations in definition order.
+// Expected events only become overdue, setting off any events triggered by
+// that, when -react is greater than 1.  Random delays are taken at the middle
+// of their ranges.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet/xscmsg"
+)
+
+// An occurrence is a single occurrence of an event in the timeline.
+type occurrence struct {
+	at      time.Time
+	due     time.Time
+	etype   definition.EventType
+	station string
+	name    string
+	note    string
+	skipped bool
+	seq     int
+	// trigger is the seq of the occurrence that triggered this one, and
+	// instance is its instance number if it is a repeating event.
+	trigger  int
+	instance int
+	// overdue indicates that this is an expected event becoming overdue,
+	// rather than happening.  overdueSeq is the seq of that occurrence, on
+	// the occurrence of the event actually happening.
+	overdue    bool
+	overdueSeq int
+}
+
+func main() {
+	var (
+		fname string
+		def   *definition.Definition
+		err   error
+		react = flag.Float64("react", 1.0, "fraction of its delay at which each expected event occurs")
+	)
+	flag.Parse()
+	switch flag.NArg() {
+	case 0:
+		fname = "exercise.def"
+	case 1:
+		fname = flag.Arg(0)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: pktex-timeline [-react fraction] [definition-file]")
+		os.Exit(2)
+	}
+	if *react < 0 {
+		fmt.Fprintln(os.Stderr, "ERROR: -react must not be negative")
+		os.Exit(2)
+	}
+	xscmsg.Register()
+	if def, err = definition.Read(fname); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	var start = def.Exercise.OpStart
+	if start.IsZero() {
+		start = time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	}
+	printTimeline(def, simulate(def, start, *react))
+}
+
+// simulate walks through the exercise definition and returns the resulting
+// occurrences in the order they happen.
+func simulate(def *definition.Definition, start time.Time, react float64) (timeline []*occurrence) {
+	var (
+		queue []*occurrence
+		seen  = make(map[string]bool)
+		seq   int
+		// happened records the stations for which each event has
+		// happened, for evaluating quorum and multiple triggers.
+		happened = make(map[string]map[string]bool)
+	)
+	var add func(o *occurrence)
+	add = func(o *occurrence) {
+		key := fmt.Sprintf("%s %s %s %d %v", o.station, o.etype, o.name, o.trigger, o.overdue)
+		if seen[key] {
+			return // as in the engine, each event happens only once per trigger
+		}
+		seen[key] = true
+		seq++
+		o.seq = seq
+		if o.instance == 0 {
+			o.instance = 1
+		}
+		queue = append(queue, o)
+		// An expected event that happens after it's due becomes overdue
+		// first.
+		if !o.overdue && !o.due.IsZero() && o.at.After(o.due) {
+			add(&occurrence{at: o.due, etype: o.etype, station: o.station, name: o.name, note: "overdue", trigger: o.trigger, overdue: true})
+			o.overdueSeq = seq
+		}
+	}
+	add(&occurrence{at: start, etype: definition.EventStart})
+	for _, stn := range def.Stations {
+		add(&occurrence{at: start, etype: definition.EventStart, station: stn.CallSign})
+	}
+	for _, edef := range def.Events {
+		if edef.TriggerType == definition.EventAt {
+			add(&occurrence{at: edef.TriggerTime, etype: definition.EventAt, name: edef.TriggerName})
+			for _, stn := range def.Stations {
+				add(&occurrence{at: edef.TriggerTime, etype: definition.EventAt, station: stn.CallSign, name: edef.TriggerName})
+			}
+		}
+	}
+	for len(queue) != 0 {
+		// Take the earliest occurrence off the queue.
+		slices.SortStableFunc(queue, func(a, b *occurrence) int {
+			if c := a.at.Compare(b.at); c != 0 {
+				return c
+			}
+			return a.seq - b.seq
+		})
+		o := queue[0]
+		queue = queue[1:]
+		timeline = append(timeline, o)
+		if o.skipped {
+			continue
+		}
+		// If it was overdue, the events triggered by that which
+		// haven't happened yet are cancelled.
+		for _, q := range queue {
+			if o.overdueSeq != 0 && q.trigger == o.overdueSeq && !q.skipped {
+				q.at, q.skipped = o.at, true
+				q.note = fmt.Sprintf("cancelled: %s %s happened", o.etype, o.name)
+			}
+		}
+		var first bool
+		if o.station != "" && !o.overdue {
+			key := fmt.Sprintf("%s %s", o.etype, o.name)
+			if happened[key] == nil {
+				happened[key] = make(map[string]bool)
+			}
+			first, happened[key][o.station] = !happened[key][o.station], true
+		}
+		// If it repeats, add its next instance, triggered by this one.
+		if edef := def.Event(o.etype, o.name); edef != nil && edef.Repeat != nil && !o.overdue {
+			var t = &occurrence{at: o.at.Add(edef.Repeat.Interval), etype: o.etype, station: o.station, name: o.name, trigger: o.seq, instance: o.instance + 1}
+			var next = t.at
+			if !o.due.IsZero() {
+				t.due = o.due.Add(edef.Repeat.Interval)
+				next = t.due
+			}
+			if edef.Repeat.Allows(def.Exercise, o.instance, next) {
+				t.note = fmt.Sprintf("repeat %d", t.instance)
+				add(t)
+			}
+		}
+		// Find the events it triggers.
+		for _, edef := range def.Events {
+			if o.overdue {
+				if !edef.IsTriggeredByOverdue(o.etype, o.name) {
+					continue
+				}
+			} else if !edef.IsTriggeredBy(o.etype, o.name, o.station == "") {
+				continue
+			}
+			if edef.TriggerStation != "" && o.station != edef.TriggerStation {
+				continue
+			}
+			if edef.TriggerQuorum != 0 && (!first || len(happened[fmt.Sprintf("%s %s", o.etype, o.name)]) != edef.Quorum(len(def.Stations))) {
+				continue
+			}
+			// An event with multiple triggers needs all of the others
+			// to have happened, or, if any one will do, none of them.
+			if edef.Triggers != nil {
+				var others int
+				for _, tr := range edef.Triggers {
+					if (tr.Type != o.etype || tr.Name != o.name) && happened[fmt.Sprintf("%s %s", tr.Type, tr.Name)][o.station] {
+						others++
+					}
+				}
+				if edef.AllTriggers && others != len(edef.Triggers)-1 || !edef.AllTriggers && others != 0 {
+					continue
+				}
+			}
+			// Events with a trigger station or quorum happen for all
+			// stations.  (Bulletins happen globally; see below.)
+			var stations = []string{o.station}
+			if edef.Type != definition.EventBulletin && edef.CrossStation() {
+				stations = stations[:0]
+				for _, stn := range def.Stations {
+					stations = append(stations, stn.CallSign)
+				}
+			}
+			// A random delay is taken at the middle of its range.
+			var delay = edef.Delay
+			if edef.DelayMax != 0 {
+				delay = ((edef.Delay + edef.DelayMax) / 2).Truncate(time.Minute)
+			}
+			for _, station := range stations {
+				var t = &occurrence{etype: edef.Type, station: station, name: edef.Name, trigger: o.seq}
+				if edef.Type == definition.EventBulletin {
+					t.station = ""
+				}
+				met, assumed := conditionMet(def, edef, station, o.at)
+				if !met {
+					t.at, t.skipped = o.at, true
+					t.note = fmt.Sprintf("skipped: condition %s not met", edef.Condition)
+					add(t)
+					continue
+				}
+				if assumed {
+					t.note = fmt.Sprintf("assuming %s", edef.Condition)
+				}
+				switch edef.Type {
+				case definition.EventBulletin:
+					t.at = o.at.Add(delay)
+					add(t)
+					for _, stn := range def.Stations {
+						add(&occurrence{at: t.at, etype: edef.Type, station: stn.CallSign, name: edef.Name, note: t.note, trigger: o.seq})
+					}
+				case definition.EventInject, definition.EventSend, definition.EventSet:
+					t.at = o.at.Add(delay)
+					add(t)
+				case definition.EventAlert, definition.EventDeliver, definition.EventReceive:
+					t.due = o.at.Add(delay)
+					t.at = o.at.Add(time.Duration(float64(delay) * react)).Truncate(time.Minute)
+					add(t)
+				}
+			}
+		}
+	}
+	return timeline
+}
+
+// conditionMet evaluates the condition on an event, for the specified station
+// at the specified time.  Variables from messages, and variables changed by set
+// events, can't be evaluated in advance; if the condition uses one, it is
+// assumed to be met, and assumed is returned true.
+func conditionMet(def *definition.Definition, edef *definition.Event, station string, at time.Time) (met, assumed bool) {
+	met = edef.ConditionMet(func(vname string) (string, bool) {
+		if slices.ContainsFunc(def.Events, func(e *definition.Event) bool {
+			return e.Type == definition.EventSet && e.Variable == vname
+		}) {
+			assumed = true
+			return "", false
+		}
+		group, item, _ := strings.Cut(vname, ".")
+		switch group {
+		case "exercise":
+			value, ok := def.Exercise.Variables[item]
+			return value, ok
+		case "station":
+			if stn := def.Station(station); stn != nil {
+				value, ok := stn.Variables[item]
+				return value, ok
+			}
+			return "", false
+		case "now":
+			switch item {
+			case "date":
+				return at.Format("01/02/2006"), true
+			case "time":
+				return at.Format("15:04"), true
+			case "datetime":
+				return at.Format("01/02/2006 15:04"), true
+			}
+			return "", false
+		default:
+			assumed = true
+			return "", false
+		}
+	})
+	if assumed {
+		return true, true
+	}
+	return met, false
+}
+
+// printTimeline prints the timeline: first the global events, then the events
+// for each station.
+func printTimeline(def *definition.Definition, timeline []*occurrence) {
+	var stations = []string{""}
+	for _, stn := range def.Stations {
+		stations = append(stations, stn.CallSign)
+	}
+	for _, station := range stations {
+		if station == "" {
+			fmt.Println("ALL")
+		} else {
+			fmt.Printf("\n%s\n", station)
+		}
+		for _, o := range timeline {
+			if o.station != station {
+				continue
+			}
+			var line = fmt.Sprintf("  %s  %-8s %s", o.at.Format("15:04"), o.etype, o.name)
+			if o.skipped {
+				line = fmt.Sprintf("  %s  (%s %s)", o.at.Format("15:04"), o.etype, o.name)
+			}
+			if !o.due.IsZero() && !o.due.Equal(o.at) {
+				line += fmt.Sprintf("  (due %s)", o.due.Format("15:04"))
+			}
+			if o.note != "" {
+				line += "  [" + o.note + "]"
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	}
+	var manual []string
+	for _, edef := range def.Events {
+		if edef.TriggerType == definition.EventManual {
+			manual = append(manual, fmt.Sprintf("%s %s", edef.Type, edef.Name))
+		}
+	}
+	if len(manual) != 0 {
+		fmt.Printf("\nManually triggered (not shown above): %s\n", strings.Join(manual, ", "))
+	}
+}
@@ -1,139 +1,381 @@
+// sim runs an exercise definition through the exercise engine under a scripted,
+// deterministic scenario, and compares the results against golden files.
+//
+// usage: sim [-def definition-file] [-seed n] [-update] [-v] scenario-file
+//
+// The scenario file is a table with three columns: a time, an action, and the
+// action's arguments.  Comments begin with a pound sign.  The actions are:
+//
+//	09:00        tick                      run a clock tick at 09:00
+//	09:00-09:30  tick                      run a clock tick every minute
+//	09:04        receive  checkin-1.txt    put a raw message in the BBS mailbox
+//	09:07        manual   deliver XND001 AskSheltStat
+//	                                       manually trigger an event
+//	09:08        reassign XND-101P XND001 CheckIn
+//	                                       reassign a rejected message
+//
+// The station or message name given to reassign can be a bullet (•) to leave
+// it unchanged or matched from the subject line, respectively.
+//
+// Times are on the date of the exercise opstart, or can be given in full as
+// 2006-01-02T15:04.  They must be in nondecreasing order.  Messages put in the
+// BBS mailbox are retrieved by the engine on the next clock tick.  The file
+// names of raw messages are relative to the scenario file.
+//
+// The exercise definition defaults to exercise.def in the same directory as the
+// scenario file.  The engine runs in a scratch directory named after the
+// scenario file, with a ".out" suffix, which is emptied before each run.  The
+// resulting exercise.log and the messages sent by the engine (in the "sent"
+// subdirectory) are compared against the files in a directory with a ".golden"
+// suffix.  With -update, the golden directory is replaced with the results
+// instead.  The random seed for random delays and message variants is 1 unless
+// -seed is given, so that the results are reproducible.
+//
+// The scenarios in testdata are run, and checked against their golden files,
+// by "go test".
 package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/rothskeller/packet-ex/definition"
 	"github.com/rothskeller/packet-ex/engine"
+	"github.com/rothskeller/packet-ex/fakebbs"
 	"github.com/rothskeller/packet-ex/server"
 	"github.com/rothskeller/packet-ex/state"
 	"github.com/rothskeller/packet/xscmsg"
 )
 
-var now time.Time
-var tick time.Time
-var e *engine.Engine
-var tickch chan time.Time
-var mtch chan server.ManualTrigger
+// A step is a single action in the scenario.
+type step struct {
+	at     time.Time
+	until  time.Time
+	action string
+	args   []string
+}
+
+// now is the simulated time, and sent is the number of messages sent through
+// the fake BBS connection.
+var (
+	now  time.Time
+	sent int
+)
 
 func main() {
 	var (
-		def *definition.Definition
-		st  *state.State
-		err error
+		scenario string
+		steps    []*step
+		def      *definition.Definition
+		st       *state.State
+		e        *engine.Engine
+		conn     *fakebbs.Connection
+		outdir   string
+		golden   string
+		err      error
+		defname  = flag.String("def", "", "exercise definition file (default exercise.def next to scenario)")
+		update   = flag.Bool("update", false, "update golden files with results")
+		verbose  = flag.Bool("v", false, "print state log entries as they are generated")
+		seed     = flag.Int64("seed", 1, "random seed for random delays and message variants")
 	)
-	os.Chdir("/Users/stever/src/packet/packet-ex/t")
-	// Read the exercise definition.  This also verifies we're in an
-	// exercise directory.
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: sim [-def definition-file] [-seed n] [-update] [-v] scenario-file")
+		os.Exit(2)
+	}
+	if scenario, err = filepath.Abs(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	if *defname == "" {
+		*defname = filepath.Join(filepath.Dir(scenario), "exercise.def")
+	}
+	base := strings.TrimSuffix(scenario, filepath.Ext(scenario))
+	outdir, golden = base+".out", base+".golden"
+	// Read the exercise definition.
 	xscmsg.Register()
-	if def, err = definition.Read("exercise.def"); err != nil {
+	if def, err = definition.Read(*defname); err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		os.Exit(1)
 	}
-	// Clean out anything left over from previous attempts.
-	os.Remove("exercise.log")
-	saved, _ := filepath.Glob("X*")
-	for _, f := range saved {
-		os.Remove(f)
+	if def.Exercise.OpStart.IsZero() {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: simulation requires an opstart time\n", *defname)
+		os.Exit(1)
 	}
-	sent, _ := filepath.Glob("*/S*")
-	for _, f := range sent {
-		os.Remove(f)
+	// Don't collide with a running engine (or another simulation).
+	def.Exercise.ListenAddr = "localhost:0"
+	// Read the scenario.
+	if steps, err = readScenario(scenario, def.Exercise.OpStart); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
 	}
-	injected, _ := filepath.Glob("*/I*")
-	for _, f := range injected {
-		os.Remove(f)
+	// Start with a clean output directory, and move into it so that all
+	// incident files are saved there.
+	if err = os.RemoveAll(outdir); err == nil {
+		err = os.MkdirAll(filepath.Join(outdir, "sent"), 0777)
+	}
+	if err == nil {
+		err = os.Chdir(outdir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
 	}
-	// Create a state tracker.
-	st = state.New(true)
-	// Set the state "now" to the opstart time.
-	now, tick = def.Exercise.OpStart, def.Exercise.OpStart
+	// Create a state tracker whose clock advances by one millisecond every
+	// time it is read, so that log entries are ordered and reproducible.
+	st = state.New(*verbose)
+	now = def.Exercise.OpStart
 	st.SetNowFunc(func() time.Time {
 		now = now.Add(time.Millisecond)
 		return now
 	})
-	// Create the exercise engine.
+	st.SetSeed(*seed)
+	// Create the exercise engine and give it a fake BBS connector.
 	if e, err = engine.New(def, st); err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		os.Exit(1)
 	}
-	// Create a fake ticker and give it to the engine.
-	tickch = make(chan time.Time)
-	e.SetTicker(tickch)
-	// Create a manual event trigger channel and give it to the engine.
-	mtch = make(chan server.ManualTrigger)
-	e.SetManualTriggerChannel(mtch)
-	// Create a fake connector.
-	e.SetBBSConnector(func(*definition.Exercise) (engine.BBSConnection, error) { return new(connection), nil })
+	if err = st.Open("exercise.log"); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	conn = &fakebbs.Connection{OnSend: recordSent}
+	e.SetBBSConnector(func(*definition.Exercise) (engine.BBSConnection, error) { return conn, nil })
 	e.SetNoInject()
-	// Run the engine.
-	go e.Run()
 	// Run the simulation steps.
-	for _, step := range steps {
-		fmt.Print("> ")
-		bufio.NewScanner(os.Stdin).Scan()
-		step()
-		// Give some time for the output from that step before printing
-		// the prompt for the next step.
-		time.Sleep(100 * time.Millisecond)
+	for _, s := range steps {
+		if s.at.After(now) {
+			now = s.at
+		}
+		switch s.action {
+		case "tick":
+			for t := s.at; !t.After(s.until); t = t.Add(time.Minute) {
+				if t.After(now) {
+					now = t
+				}
+				e.ClockTick(t)
+			}
+		case "receive":
+			conn.Mailbox = append(conn.Mailbox, s.args[0])
+		case "manual":
+			etype, _ := definition.ParseEventType(s.args[0])
+			e.ManualTrigger(server.ManualTrigger{Type: etype, Station: s.args[1], Name: s.args[2]})
+		case "reassign":
+			for i := range s.args {
+				if s.args[i] == "•" {
+					s.args[i] = ""
+				}
+			}
+			e.ManualTrigger(server.ManualTrigger{Type: definition.EventReceive, Station: s.args[1], Name: s.args[2], LMI: s.args[0]})
+		}
+	}
+	// Compare the results with the golden files.
+	if err = os.Chdir(filepath.Dir(outdir)); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
 	}
+	if *update {
+		if err = updateGolden(outdir, golden); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("updated %s\n", golden)
+		return
+	}
+	if diffs := compareGolden(outdir, golden); len(diffs) != 0 {
+		for _, d := range diffs {
+			fmt.Println(d)
+		}
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
 }
 
-var steps = []func(){}
+var scenarioTimeRE = regexp.MustCompile(`^(\d\d:\d\d|\d{4}-\d\d-\d\dT\d\d:\d\d)(?:-(\d\d:\d\d|\d{4}-\d\d-\d\dT\d\d:\d\d))?$`)
 
-func runTick(h, m int) {
-	tick = time.Date(2023, 9, 23, h, m, 0, 0, time.Local)
-	now = tick
-	fmt.Printf("TICK = %s\n", now.Format("15:04"))
-	tickch <- tick
+// readScenario reads and parses the scenario file.
+func readScenario(fname string, opstart time.Time) (steps []*step, err error) {
+	var (
+		fh    *os.File
+		scan  *bufio.Scanner
+		lnum  int
+		last  time.Time
+		fdir  = filepath.Dir(fname)
+		parse = func(s string) (t time.Time) {
+			if len(s) == 5 {
+				t, _ = time.ParseInLocation("2006-01-02 15:04", opstart.Format("2006-01-02 ")+s, time.Local)
+			} else {
+				t, _ = time.ParseInLocation("2006-01-02T15:04", s, time.Local)
+			}
+			return t
+		}
+	)
+	if fh, err = os.Open(fname); err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	scan = bufio.NewScanner(fh)
+	for scan.Scan() {
+		var s step
+
+		lnum++
+		line, _, _ := strings.Cut(scan.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: syntax error", fname, lnum)
+		}
+		s.action, s.args = fields[1], fields[2:]
+		if match := scenarioTimeRE.FindStringSubmatch(fields[0]); match == nil {
+			return nil, fmt.Errorf("%s:%d: invalid time %q", fname, lnum, fields[0])
+		} else {
+			s.at, s.until = parse(match[1]), parse(match[1])
+			if match[2] != "" {
+				if s.action != "tick" {
+					return nil, fmt.Errorf("%s:%d: time ranges are allowed only for ticks", fname, lnum)
+				}
+				s.until = parse(match[2])
+			}
+		}
+		if s.at.IsZero() || s.until.Before(s.at) {
+			return nil, fmt.Errorf("%s:%d: invalid time %q", fname, lnum, fields[0])
+		}
+		if s.at.Before(last) {
+			return nil, fmt.Errorf("%s:%d: time is earlier than previous step", fname, lnum)
+		}
+		last = s.until
+		switch s.action {
+		case "tick":
+			if len(s.args) != 0 {
+				return nil, fmt.Errorf("%s:%d: tick takes no arguments", fname, lnum)
+			}
+		case "receive":
+			if len(s.args) != 1 {
+				return nil, fmt.Errorf("%s:%d: usage: TIME receive FILENAME", fname, lnum)
+			}
+			raw, err := os.ReadFile(filepath.Join(fdir, s.args[0]))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", fname, lnum, err)
+			}
+			s.args[0] = string(raw)
+		case "manual":
+			if len(s.args) != 3 {
+				return nil, fmt.Errorf("%s:%d: usage: TIME manual TYPE STATION NAME", fname, lnum)
+			}
+			if _, err := definition.ParseEventType(s.args[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", fname, lnum, err)
+			}
+		case "reassign":
+			if len(s.args) != 3 {
+				return nil, fmt.Errorf("%s:%d: usage: TIME reassign LMI STATION NAME", fname, lnum)
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown action %q", fname, lnum, s.action)
+		}
+		steps = append(steps, &s)
+	}
+	if err = scan.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %s", fname, err)
+	}
+	return steps, nil
 }
 
-type connection struct{}
+// recordSent records a message sent through the fake BBS connection in the
+// "sent" directory.
+func recordSent(subject, body string, to []string) error {
+	sent++
+	content := fmt.Sprintf("Time: %s\nTo: %s\nSubject: %s\n\n%s",
+		now.Format("2006-01-02 15:04"), strings.Join(to, ", "), subject, body)
+	return os.WriteFile(filepath.Join("sent", fmt.Sprintf("%03d.txt", sent)), []byte(content), 0666)
+}
 
-func (c *connection) Read(msgnum int) (string, error) {
-	fmt.Printf("CONN: read %d\n", msgnum)
-	if c, err := os.ReadFile(fmt.Sprintf("%s/R%d", tick.Format("15:04"), msgnum)); os.IsNotExist(err) {
-		return "", nil
-	} else if err != nil {
-		return "", err
-	} else {
-		return string(c), nil
+// resultFiles returns the names of the files that are compared against golden
+// files, relative to dir.
+func resultFiles(dir string) (files []string) {
+	if _, err := os.Stat(filepath.Join(dir, "exercise.log")); err == nil {
+		files = append(files, "exercise.log")
 	}
+	sent, _ := filepath.Glob(filepath.Join(dir, "sent", "*.txt"))
+	for _, f := range sent {
+		files = append(files, filepath.Join("sent", filepath.Base(f)))
+	}
+	return files
 }
 
-func (c *connection) Kill(msgnum ...int) error {
-	fmt.Printf("CONN: kill %d\n", msgnum[0])
-	return nil
+// compareGolden compares the results in outdir against the golden files, and
+// returns a description of each difference.
+func compareGolden(outdir, golden string) (diffs []string) {
+	var have, want = resultFiles(outdir), resultFiles(golden)
+
+	if len(want) == 0 {
+		return []string{fmt.Sprintf("%s: no golden files (run with -update to create them)", golden)}
+	}
+	for _, f := range want {
+		if !slices.Contains(have, f) {
+			diffs = append(diffs, fmt.Sprintf("%s: missing from results", f))
+		}
+	}
+	for _, f := range have {
+		if !slices.Contains(want, f) {
+			diffs = append(diffs, fmt.Sprintf("%s: not in golden files", f))
+			continue
+		}
+		hb, err1 := os.ReadFile(filepath.Join(outdir, f))
+		wb, err2 := os.ReadFile(filepath.Join(golden, f))
+		if err := errors.Join(err1, err2); err != nil {
+			diffs = append(diffs, fmt.Sprintf("%s: %s", f, err))
+		} else if d := compareLines(hb, wb); d != "" {
+			diffs = append(diffs, fmt.Sprintf("%s:%s", f, d))
+		}
+	}
+	return diffs
 }
 
-func (c *connection) Send(subject string, body string, to ...string) error {
-	outnum := 1
-	var fname string
-	for {
-		fname = fmt.Sprintf("%s/S%d", tick.Format("15:04"), outnum)
-		if _, err := os.Stat(fname); os.IsNotExist(err) {
-			break
-		}
-		outnum++
-	}
-	os.Mkdir(tick.Format("15:04"), 0777)
-	fh, _ := os.Create(fname)
-	fmt.Printf("CONN: send %s\n", strings.Join(to, " "))
-	fmt.Fprintf(fh, "CONN: send %s\n", strings.Join(to, " "))
-	// fmt.Printf("Subject: %s\n", subject)
-	fmt.Fprintf(fh, "Subject: %s\n", subject)
-	// fmt.Println(body)
-	fmt.Fprint(fh, body)
-	fh.Close()
-	return nil
+// compareLines compares two files line by line, and returns a description of
+// the first difference, or an empty string if there are none.
+func compareLines(have, want []byte) string {
+	hl, wl := bytes.Split(have, []byte("\n")), bytes.Split(want, []byte("\n"))
+	for i := 0; i < len(hl) || i < len(wl); i++ {
+		switch {
+		case i >= len(hl):
+			return fmt.Sprintf("%d: missing line\n  want %s", i+1, wl[i])
+		case i >= len(wl):
+			return fmt.Sprintf("%d: extra line\n  have %s", i+1, hl[i])
+		case !bytes.Equal(hl[i], wl[i]):
+			return fmt.Sprintf("%d: lines differ\n  have %s\n  want %s", i+1, hl[i], wl[i])
+		}
+	}
+	return ""
 }
 
-func (c *connection) Close() error {
-	fmt.Println("CONN: close")
+// updateGolden replaces the golden files with the results in outdir.
+func updateGolden(outdir, golden string) (err error) {
+	if err = os.RemoveAll(golden); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Join(golden, "sent"), 0777); err != nil {
+		return err
+	}
+	for _, f := range resultFiles(outdir) {
+		var contents []byte
+		if contents, err = os.ReadFile(filepath.Join(outdir, f)); err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(golden, f), contents, 0666); err != nil {
+			return err
+		}
+	}
 	return nil
 }
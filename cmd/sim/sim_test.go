@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs the simulator itself, rather than the tests, when the test
+// binary is re-executed by TestScenarios.  Each scenario gets its own process
+// because the engine keeps some package-level state (e.g., the message cache).
+func TestMain(m *testing.M) {
+	if os.Getenv("PKTEX_SIM") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestScenarios runs every scenario under testdata and compares its results
+// with its golden files.
+func TestScenarios(t *testing.T) {
+	scenarios, err := filepath.Glob(filepath.Join("testdata", "*", "*.scenario"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("no scenarios found")
+	}
+	for _, scenario := range scenarios {
+		t.Run(filepath.Base(filepath.Dir(scenario)), func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], scenario)
+			cmd.Env = append(os.Environ(), "PKTEX_SIM=1")
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Errorf("%s: %s\n%s", scenario, err, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,435 @@
+package definition
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Condition is a parsed event trigger condition.  It is a boolean expression
+// combining comparisons with "and", "or", "not", and parentheses.
+type Condition struct {
+	text string
+	root condNode
+}
+
+// String returns the condition as it was written in the definition.
+func (c *Condition) String() string { return c.text }
+
+// Met returns whether the condition is met.  lookup is called to get the
+// value of each variable in the condition; it returns false if the variable
+// has no value, in which case any comparison involving it is false.
+func (c *Condition) Met(lookup func(vname string) (string, bool)) bool {
+	return c.root.met(lookup)
+}
+
+// Variables returns the names of the variables used in the condition, in the
+// order they appear.
+func (c *Condition) Variables() (vars []string) {
+	return c.root.variables(nil)
+}
+
+// A condNode is a node of the condition expression tree.
+type condNode interface {
+	met(lookup func(string) (string, bool)) bool
+	variables(vars []string) []string
+}
+
+type condAnd struct{ left, right condNode }
+
+func (c *condAnd) met(lookup func(string) (string, bool)) bool {
+	return c.left.met(lookup) && c.right.met(lookup)
+}
+func (c *condAnd) variables(vars []string) []string {
+	return c.right.variables(c.left.variables(vars))
+}
+
+type condOr struct{ left, right condNode }
+
+func (c *condOr) met(lookup func(string) (string, bool)) bool {
+	return c.left.met(lookup) || c.right.met(lookup)
+}
+func (c *condOr) variables(vars []string) []string {
+	return c.right.variables(c.left.variables(vars))
+}
+
+type condNot struct{ sub condNode }
+
+func (c *condNot) met(lookup func(string) (string, bool)) bool { return !c.sub.met(lookup) }
+func (c *condNot) variables(vars []string) []string            { return c.sub.variables(vars) }
+
+// A condCompare is a comparison between two operands.  re is the compiled
+// regular expression for a "≈" comparison against a constant.
+type condCompare struct {
+	left, right condOperand
+	op          string
+	re          *regexp.Regexp
+}
+
+func (c *condCompare) met(lookup func(string) (string, bool)) bool {
+	left, ok := c.left.value(lookup)
+	if !ok {
+		return false
+	}
+	right, ok := c.right.value(lookup)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "≈":
+		var re = c.re
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(right); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(left)
+	case "=":
+		return compareValues(left, right) == 0
+	case "!=":
+		return compareValues(left, right) != 0
+	case "<":
+		return compareValues(left, right) < 0
+	case "<=":
+		return compareValues(left, right) <= 0
+	case ">":
+		return compareValues(left, right) > 0
+	case ">=":
+		return compareValues(left, right) >= 0
+	}
+	// The condition parser shouldn't let anything else through, so this is
+	// a software bug.
+	panic("not reachable")
+}
+func (c *condCompare) variables(vars []string) []string {
+	if c.left.variable != "" {
+		vars = append(vars, c.left.variable)
+	}
+	if c.right.variable != "" {
+		vars = append(vars, c.right.variable)
+	}
+	return vars
+}
+
+// A condOperand is one side of a comparison: either a variable or a constant.
+type condOperand struct {
+	variable string
+	constant string
+}
+
+func (o condOperand) value(lookup func(string) (string, bool)) (string, bool) {
+	if o.variable != "" {
+		return lookup(o.variable)
+	}
+	return o.constant, true
+}
+
+// compareValues compares two values.  If both are numbers, they are compared
+// numerically.  If both are dates, both are times, or both are date/times,
+// they are compared chronologically.  If one is a date/time and the other is a
+// time, the time of day of the date/time is compared with the time.
+// Otherwise, they are compared as strings.
+func compareValues(a, b string) int {
+	if af, err := strconv.ParseFloat(a, 64); err == nil {
+		if bf, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	if at, akind := parseDateTime(a); akind != 0 {
+		if bt, bkind := parseDateTime(b); bkind == akind {
+			return at.Compare(bt)
+		} else if (akind == 3 && bkind == 2) || (akind == 2 && bkind == 3) {
+			return timeOfDay(at).Compare(timeOfDay(bt))
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// dateTimeFormats are the date and time formats recognized in comparisons,
+// with the kind of value each represents: 1 for a date, 2 for a time, and 3
+// for a date/time.
+var dateTimeFormats = []struct {
+	layout string
+	kind   int
+}{
+	{"2006-01-02T15:04", 3},
+	{"2006-01-02 15:04", 3},
+	{"01/02/2006 15:04", 3},
+	{"2006-01-02", 1},
+	{"01/02/2006", 1},
+	{"15:04", 2},
+}
+
+// parseDateTime parses a date, time, or date/time value.  It returns a zero
+// kind if the value isn't one of those.
+func parseDateTime(s string) (t time.Time, kind int) {
+	for _, f := range dateTimeFormats {
+		if t, err := time.ParseInLocation(f.layout, s, time.Local); err == nil {
+			return t, f.kind
+		}
+	}
+	return time.Time{}, 0
+}
+
+// timeOfDay returns the time of day of t, on the same (zero) date that
+// parseDateTime gives to a time without a date.
+func timeOfDay(t time.Time) time.Time {
+	return time.Date(0, 1, 1, t.Hour(), t.Minute(), 0, 0, time.Local)
+}
+
+// condVariableRE matches the syntax of a variable name in a condition.
+// Whether the variable actually exists is checked separately.
+var condVariableRE = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*\.[A-Za-z][-A-Za-z0-9_]*$`)
+
+// condChevronRE matches the syntax of a variable name in chevrons in a
+// condition.  It is more permissive, since the field label in a message
+// variable can contain almost anything.
+var condChevronRE = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*\.[^«»]+$`)
+
+// condParser is a recursive descent parser for conditions.
+type condParser struct {
+	s   string
+	pos int
+}
+
+// parseCondition parses a condition.
+func parseCondition(s string) (c *Condition, err error) {
+	var p = condParser{s: s}
+
+	c = &Condition{text: s}
+	if c.root, err = p.parseOr(); err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+	return c, nil
+}
+
+// parseOr parses a sequence of subexpressions joined by "or".
+func (p *condParser) parseOr() (n condNode, err error) {
+	if n, err = p.parseAnd(); err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		var right condNode
+		if right, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		n = &condOr{n, right}
+	}
+	return n, nil
+}
+
+// parseAnd parses a sequence of subexpressions joined by "and".
+func (p *condParser) parseAnd() (n condNode, err error) {
+	if n, err = p.parseNot(); err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		var right condNode
+		if right, err = p.parseNot(); err != nil {
+			return nil, err
+		}
+		n = &condAnd{n, right}
+	}
+	return n, nil
+}
+
+// parseNot parses a subexpression optionally preceded by "not".
+func (p *condParser) parseNot() (n condNode, err error) {
+	if p.keyword("not") {
+		if n, err = p.parseNot(); err != nil {
+			return nil, err
+		}
+		return &condNot{n}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a parenthesized subexpression or a comparison.
+func (p *condParser) parsePrimary() (n condNode, err error) {
+	var cmp condCompare
+
+	if p.skipSpace(); p.pos < len(p.s) && p.s[p.pos] == '(' {
+		p.pos++
+		if n, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		if p.skipSpace(); p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return n, nil
+	}
+	if cmp.left, err = p.parseLeftOperand(); err != nil {
+		return nil, err
+	}
+	if cmp.op = p.operator(); cmp.op == "" {
+		return nil, fmt.Errorf("expected comparison operator after %q", p.s[:p.pos])
+	}
+	if cmp.right, err = p.parseRightOperand(); err != nil {
+		return nil, err
+	}
+	if cmp.op == "≈" && cmp.right.variable == "" {
+		if cmp.re, err = regexp.Compile(cmp.right.constant); err != nil {
+			return nil, errors.New("syntax error in regular expression")
+		}
+	}
+	return &cmp, nil
+}
+
+// parseLeftOperand parses the operand on the left side of a comparison: a
+// quoted string, a variable name in chevrons, or a single word that is either a
+// variable name or a constant.
+func (p *condParser) parseLeftOperand() (o condOperand, err error) {
+	if p.skipSpace(); p.pos < len(p.s) && p.s[p.pos] == '"' {
+		o.constant, err = p.quoted()
+		return o, err
+	}
+	if strings.HasPrefix(p.s[p.pos:], "«") {
+		o.variable, err = p.chevrons()
+		return o, err
+	}
+	var start = p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t()\"=!<>", rune(p.s[p.pos])) && !strings.HasPrefix(p.s[p.pos:], "≈") {
+		p.pos++
+	}
+	if word := p.s[start:p.pos]; word == "" {
+		return o, fmt.Errorf("expected comparison after %q", p.s[:start])
+	} else if condVariableRE.MatchString(word) {
+		o.variable = word
+	} else {
+		o.constant = word
+	}
+	return o, nil
+}
+
+// parseRightOperand parses the operand on the right side of a comparison.  It
+// can be a quoted string or a variable name in chevrons.  Otherwise, it extends to the end of the condition,
+// the word "and" or "or", or a close parenthesis that doesn't match an open
+// parenthesis in the operand, whichever comes first.  If what results is a
+// variable name, the operand is that variable; otherwise, it's a constant.
+func (p *condParser) parseRightOperand() (o condOperand, err error) {
+	if p.skipSpace(); p.pos < len(p.s) && p.s[p.pos] == '"' {
+		o.constant, err = p.quoted()
+		return o, err
+	}
+	if strings.HasPrefix(p.s[p.pos:], "«") {
+		o.variable, err = p.chevrons()
+		return o, err
+	}
+	var start, depth = p.pos, 0
+LOOP:
+	for ; p.pos < len(p.s); p.pos++ {
+		switch p.s[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				break LOOP
+			}
+			depth--
+		case ' ', '\t':
+			if p.atKeyword("and") || p.atKeyword("or") {
+				break LOOP
+			}
+		}
+	}
+	if word := strings.TrimSpace(p.s[start:p.pos]); word == "" {
+		return o, fmt.Errorf("missing value after %q", strings.TrimSpace(p.s[:start]))
+	} else if condVariableRE.MatchString(word) {
+		o.variable = word
+	} else {
+		o.constant = word
+	}
+	return o, nil
+}
+
+// quoted parses a double-quoted string.  Within it, a backslash causes the
+// following character to be taken literally.
+func (p *condParser) quoted() (s string, err error) {
+	var sb strings.Builder
+
+	for p.pos++; p.pos < len(p.s); p.pos++ {
+		switch p.s[p.pos] {
+		case '"':
+			p.pos++
+			return sb.String(), nil
+		case '\\':
+			if p.pos++; p.pos < len(p.s) {
+				sb.WriteByte(p.s[p.pos])
+			}
+		default:
+			sb.WriteByte(p.s[p.pos])
+		}
+	}
+	return "", errors.New("unterminated quoted string")
+}
+
+// chevrons parses a variable name in chevrons.  This is needed for variable
+// names containing spaces or punctuation, such as message field labels.
+func (p *condParser) chevrons() (vname string, err error) {
+	var idx int
+
+	p.pos += len("«")
+	if idx = strings.Index(p.s[p.pos:], "»"); idx < 0 {
+		return "", errors.New("unmatched «")
+	}
+	vname, p.pos = p.s[p.pos:p.pos+idx], p.pos+idx+len("»")
+	if !condChevronRE.MatchString(vname) {
+		return "", fmt.Errorf("invalid variable name «%s»", vname)
+	}
+	return vname, nil
+}
+
+// operator parses a comparison operator, returning "" if there isn't one.
+func (p *condParser) operator() string {
+	p.skipSpace()
+	for _, op := range []string{"!=", "<=", ">=", "=", "<", ">", "≈"} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// keyword parses the specified keyword, returning whether it was there.
+func (p *condParser) keyword(kw string) bool {
+	p.skipSpace()
+	if p.atKeyword(kw) {
+		p.pos += len(kw)
+		return true
+	}
+	return false
+}
+
+// atKeyword returns whether the specified keyword, optionally preceded by
+// whitespace, is next in the condition.  The keyword must be followed by
+// whitespace or a parenthesis.
+func (p *condParser) atKeyword(kw string) bool {
+	rest := strings.TrimLeft(p.s[p.pos:], " \t")
+	if !strings.HasPrefix(rest, kw) {
+		return false
+	}
+	rest = rest[len(kw):]
+	return rest != "" && (rest[0] == ' ' || rest[0] == '\t' || rest[0] == '(')
+}
+
+func (p *condParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
@@ -0,0 +1,129 @@
+package definition
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"9", "10", -1},
+		{"10.0", "10", 0},
+		{"abc", "abd", -1},
+		{"9", "abc", -1},
+		{"09/23/2023", "2023-09-22", 1},
+		{"09:30", "10:00", -1},
+		{"09/23/2023 09:30", "2023-09-23T09:30", 0},
+		{"09/23/2023 09:30", "09/22/2023 10:00", 1},
+		// A date/time compared with a time uses its time of day.
+		{"09/23/2023 09:30", "10:00", -1},
+		{"09/23/2023 10:30", "10:00", 1},
+		{"09/23/2023 10:00", "10:00", 0},
+		{"10:00", "09/23/2023 09:30", 1},
+		// A date compared with a time is compared as strings.
+		{"09/23/2023", "10:00", -1},
+	}
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestConditionCutoff(t *testing.T) {
+	cond, err := parseCondition("station.type = shelter and CheckIn.time < exercise.cutoff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{
+		"station.type":    "shelter",
+		"exercise.cutoff": "10:30",
+	}
+	lookup := func(vname string) (string, bool) {
+		value, ok := vars[vname]
+		return value, ok
+	}
+	for _, tt := range []struct {
+		time string
+		want bool
+	}{
+		{"09/23/2023 10:29", true},
+		{"09/23/2023 10:30", false},
+		{"09/24/2023 09:00", true},
+	} {
+		vars["CheckIn.time"] = tt.time
+		if got := cond.Met(lookup); got != tt.want {
+			t.Errorf("CheckIn.time %s: Met = %v, want %v", tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	vars := map[string]string{
+		"station.type":             "shelter",
+		"station.name":             "Bread and Butter",
+		"CheckIn.subject":          "Check-In XND001",
+		"SheltStat.Shelter Status": "Open",
+		"exercise.count":           "9",
+	}
+	lookup := func(vname string) (string, bool) {
+		value, ok := vars[vname]
+		return value, ok
+	}
+	tests := []struct {
+		cond    string
+		vars    []string
+		met     bool
+		wantErr string
+	}{
+		{cond: "station.type = shelter", vars: []string{"station.type"}, met: true},
+		{cond: "station.type != shelter", vars: []string{"station.type"}, met: false},
+		{cond: "exercise.count < 10", vars: []string{"exercise.count"}, met: true},
+		{cond: "station.name = Bread and Butter", wantErr: `expected comparison operator after "station.name = Bread and Butter"`},
+		{cond: `station.name = "Bread and Butter"`, vars: []string{"station.name"}, met: true},
+		{cond: `station.name = "Bread \"and\" Butter"`, vars: []string{"station.name"}, met: false},
+		{cond: "«SheltStat.Shelter Status» = Open", vars: []string{"SheltStat.Shelter Status"}, met: true},
+		{cond: "CheckIn.subject ≈ ^Check-In [A-Z0-9]+$", vars: []string{"CheckIn.subject"}, met: true},
+		{cond: "station.type = eoc or station.type = shelter", vars: []string{"station.type", "station.type"}, met: true},
+		{cond: "not station.type = eoc and exercise.count >= 9", vars: []string{"station.type", "exercise.count"}, met: true},
+		{cond: "not (station.type = shelter or exercise.count > 100)", vars: []string{"station.type", "exercise.count"}, met: false},
+		{cond: "station.type = eoc or station.type = shelter and exercise.count > 100", vars: []string{"station.type", "station.type", "exercise.count"}, met: false},
+		{cond: "(station.type = eoc or station.type = shelter) and exercise.count = 9", vars: []string{"station.type", "station.type", "exercise.count"}, met: true},
+		{cond: "station.type = (shelter)", vars: []string{"station.type"}, met: false},
+		{cond: "station.missing = x or station.type = shelter", vars: []string{"station.missing", "station.type"}, met: true},
+		{cond: "station.missing != x", vars: []string{"station.missing"}, met: false},
+		{cond: "station.type = «station.type»", vars: []string{"station.type", "station.type"}, met: true},
+		{cond: "", wantErr: `expected comparison after ""`},
+		{cond: "station.type", wantErr: `expected comparison operator after "station.type"`},
+		{cond: "station.type =", wantErr: `missing value after "station.type ="`},
+		{cond: "(station.type = shelter", wantErr: "missing )"},
+		{cond: `station.name = "Bread`, wantErr: "unterminated quoted string"},
+		{cond: "«SheltStat.Shelter Status = Open", wantErr: "unmatched «"},
+		{cond: "CheckIn.subject ≈ ([", wantErr: "syntax error in regular expression"},
+	}
+	for _, tt := range tests {
+		cond, err := parseCondition(tt.cond)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseCondition(%q): err = %v, want %q", tt.cond, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCondition(%q): unexpected error %v", tt.cond, err)
+			continue
+		}
+		if cond.String() != tt.cond {
+			t.Errorf("parseCondition(%q): String() = %q", tt.cond, cond.String())
+		}
+		if got := cond.Variables(); !slices.Equal(got, tt.vars) {
+			t.Errorf("parseCondition(%q): Variables() = %q, want %q", tt.cond, got, tt.vars)
+		}
+		if got := cond.Met(lookup); got != tt.met {
+			t.Errorf("parseCondition(%q): Met = %v, want %v", tt.cond, got, tt.met)
+		}
+	}
+}
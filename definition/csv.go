@@ -0,0 +1,89 @@
+package definition
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readStationsCSV reads the station list from a CSV file, such as one exported
+// from a signup spreadsheet.  The first row must contain the column headings;
+// they are the same as for a [STATIONS] section.  Error messages refer to the
+// CSV file and its line numbers.
+func (def *Definition) readStationsCSV(filename string) (err error) {
+	var (
+		fh    *os.File
+		rdr   *csv.Reader
+		table [][]string
+		start int
+	)
+	if fh, err = os.Open(filename); err != nil {
+		return err
+	}
+	defer fh.Close()
+	def.Sources = append(def.Sources, filename)
+	rdr = csv.NewReader(fh)
+	rdr.TrimLeadingSpace = true
+	for {
+		row, err := rdr.Read()
+		if err == io.EOF {
+			break
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return fmt.Errorf("%s:%d: %s", filename, perr.Line, perr.Err)
+		} else if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+		// The CSV reader skips blank lines, and quoted values can span
+		// lines, so row numbers aren't line numbers.  Put each row in
+		// the table at the index of its line relative to the heading
+		// row, with nil rows for the lines in between, the same as for
+		// a table in the definition file.
+		line, _ := rdr.FieldPos(0)
+		if start == 0 {
+			start = line
+			if len(row) != 0 {
+				row[0] = strings.TrimPrefix(row[0], "\ufeff") // spreadsheet byte order mark
+			}
+		}
+		var blank = true
+		for i := range row {
+			if row[i] = strings.TrimSpace(row[i]); row[i] != "" {
+				blank = false
+			}
+		}
+		if blank && len(table) != 0 {
+			row = nil // rows left empty in the spreadsheet are ignored
+		}
+		for len(table) < line-start {
+			table = append(table, nil)
+		}
+		table = append(table, row)
+	}
+	// Row numbers in errors from parseStations are relative to start, so
+	// they are CSV line numbers.
+	if start == 0 {
+		start = 1 // empty file
+	}
+	if err = def.parseStations(table, start); err != nil {
+		return fmt.Errorf("%s:%s", filename, err)
+	}
+	return nil
+}
+
+// readStationsSidecar reads the station list from a stations.csv file in the
+// same directory as the exercise definition file, if there is one.  It
+// returns false if there isn't.
+func (def *Definition) readStationsSidecar() (found bool, err error) {
+	var filename = filepath.Join(filepath.Dir(def.Filename), "stations.csv")
+
+	if _, err = os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return true, def.readStationsCSV(filename)
+}
@@ -0,0 +1,88 @@
+package definition
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadStationsCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []string // call signs
+		wantErr string
+	}{
+		{
+			name: "simple",
+			csv:  "callsign,prefix\nXND001,XNA\nXND002,XNB\n",
+			want: []string{"XND001", "XND002"},
+		},
+		{
+			name: "byte order mark and empty row",
+			csv:  "\ufeffcallsign,prefix\nXND001,XNA\n,\nXND002,XNB\n",
+			want: []string{"XND001", "XND002"},
+		},
+		{
+			name:    "error after blank lines",
+			csv:     "callsign,prefix\n\nXND001,XNA\n\n\nXND002,X\n",
+			wantErr: "stations.csv:6: prefix column does not contain a valid message ID prefix",
+		},
+		{
+			name:    "error after multi-line value",
+			csv:     "callsign,location\nXND001,\"Main St\nSuite 2\"\nXND001,Elm St\n",
+			wantErr: "stations.csv:4: multiple lines with callsign \"XND001\"",
+		},
+		{
+			name:    "heading after blank lines",
+			csv:     "\n\ncallsign,prefix\nXND001,XN\n",
+			wantErr: "stations.csv:4: prefix column does not contain a valid message ID prefix",
+		},
+		{
+			name:    "non-ASCII column name",
+			csv:     "\n\ncallsign,prefix,h\u00f4tel\nXND001,XNA,\n",
+			wantErr: "stations.csv:3: column 3 name is not ASCII",
+		},
+		{
+			name:    "parse error",
+			csv:     "callsign,prefix\nXND001,XNA\n\nXND002,X\"B\n",
+			wantErr: "stations.csv:4: bare \" in non-quoted-field",
+		},
+		{
+			name:    "wrong number of fields",
+			csv:     "callsign,prefix\n\nXND001\n",
+			wantErr: "stations.csv:3: wrong number of fields",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fname = filepath.Join(t.TempDir(), "stations.csv")
+			if err := os.WriteFile(fname, []byte(tt.csv), 0666); err != nil {
+				t.Fatal(err)
+			}
+			var def Definition
+			err := def.readStationsCSV(fname)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != filepath.Dir(fname)+string(filepath.Separator)+tt.wantErr {
+					t.Errorf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			var got []string
+			for _, stn := range def.Stations {
+				got = append(got, stn.CallSign)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got stations %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got stations %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
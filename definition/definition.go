@@ -3,16 +3,19 @@ package definition
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"time"
 )
 
 type Definition struct {
 	Filename       string
+	Sources        []string
 	Exercise       *Exercise
 	FormValidation map[string]*FormValidation
 	Stations       []*Station
 	Events         []*Event
 	MatchReceive   []*MatchReceive
+	Rejects        *Rejects
 	Bulletin       map[string]*Bulletin
 	Send           map[string]*Message
 	Receive        map[string]*Message
@@ -37,6 +40,23 @@ func (d *Definition) Event(etype EventType, name string) *Event {
 	return nil
 }
 
+// Feedback returns the feedback setting for the named received message.  It is
+// "none" if the engine sends no feedback to the station after scoring the
+// message, "problems" if it sends a list of the problems found, or "score" if it
+// sends the problems and the score.  The setting in the [MATCH RECEIVE] row for
+// the message overrides the one in the [EXERCISE] section.
+func (d *Definition) Feedback(name string) string {
+	for _, mr := range d.MatchReceive {
+		if mr.Name == name && mr.Feedback != "" {
+			return mr.Feedback
+		}
+	}
+	if d.Exercise.Feedback != "" {
+		return d.Exercise.Feedback
+	}
+	return "none"
+}
+
 type Exercise struct {
 	ListenAddr   string
 	Incident     string
@@ -57,7 +77,16 @@ type Exercise struct {
 	SMTPUser     string
 	SMTPPassword string
 	StartMsgID   string
-	Variables    map[string]string
+	// Feedback is the default feedback setting for received messages; see
+	// Definition.Feedback.  It is empty if not specified.
+	Feedback string
+	// FuzzyMatch says what to do with a received message that doesn't
+	// match any [MATCH RECEIVE] entry exactly, but is similar to one:
+	// "off" to reject it, "suggest" (the default) to reject it but offer
+	// the similar message name in the monitor, or "auto" to accept it as
+	// that message name with a warning.
+	FuzzyMatch string
+	Variables  map[string]string
 }
 
 const PackItForms = "PackItForms"
@@ -91,12 +120,15 @@ const (
 	EventSend
 	EventDeliver
 	EventAlert
+	EventSet
 	// internal only:
 	EventReceipt
 	EventReject
+	EventFeedback
 	// triggers but not real events:
 	EventStart
 	EventManual
+	EventAt
 )
 
 func (et EventType) String() string { return eventTypeNames[et] }
@@ -111,16 +143,178 @@ func ParseEventType(s string) (et EventType, err error) {
 }
 
 type Event struct {
-	Group        string
-	Type         EventType
-	Name         string
-	TriggerType  EventType
-	TriggerName  string
-	ConditionVar string
-	ConditionOp  string
-	ConditionVal string
-	ConditionRE  *regexp.Regexp
-	Delay        time.Duration
+	Group       string
+	Type        EventType
+	Name        string
+	TriggerType EventType
+	TriggerName string
+	// TriggerTime is the time of the trigger for an event with an "at"
+	// trigger.  (TriggerName is the time as written in the definition.)
+	TriggerTime time.Time
+	// TriggerOverdue indicates that the event is triggered when the
+	// triggering event (an alert, deliver, or receive) becomes overdue,
+	// rather than when it happens.
+	TriggerOverdue bool
+	// TriggerStation, if set, limits the trigger to happenings of the
+	// triggering event for that station.  TriggerQuorum, if nonzero, limits
+	// it to the happening that brings the number of stations for which the
+	// triggering event has happened up to TriggerQuorum (or, if
+	// TriggerPercent is true, up to TriggerQuorum percent of the stations).
+	// An event with either of these happens for every station, or globally
+	// if it is a bulletin.
+	TriggerStation string
+	TriggerQuorum  int
+	TriggerPercent bool
+	// Triggers lists the triggers of an event that has more than one
+	// (TriggerType and TriggerName are the first of them).  It is nil for
+	// events with a single trigger.  If AllTriggers is true, the event
+	// happens when all of them have happened, with its delay counted from
+	// the last one.  Otherwise, it happens when the first of them happens.
+	Triggers    []Trigger
+	AllTriggers bool
+	Condition   *Condition
+	// Delay is the delay between the trigger and the event.  If DelayMax
+	// is nonzero, the delay is random, chosen between Delay and DelayMax.
+	Delay    time.Duration
+	DelayMax time.Duration
+	React    time.Duration
+	// Variable and Value are the variable to be set, and the value to set
+	// it to, for a set event.
+	Variable string
+	Value    StringWithInterps
+	// Repeat describes how the event repeats after it first happens.  It
+	// is nil for events that happen only once.
+	Repeat *Repeat
+}
+
+// A Trigger is one of the triggers of an event that has more than one.
+type Trigger struct {
+	Type EventType
+	Name string
+}
+
+// A Repeat describes the repetition of an event.  Each instance of the event
+// happens Interval after the previous one, until Count instances have happened
+// (if Count is nonzero) or until the Until time of day (if UntilTime is true)
+// or the exercise end time (if UntilOpEnd is true).
+type Repeat struct {
+	Interval   time.Duration
+	Count      int
+	Until      time.Duration
+	UntilTime  bool
+	UntilOpEnd bool
+}
+
+// Allows returns whether another instance of a repeating event should happen
+// at the specified time, given that count instances have already happened.
+func (r *Repeat) Allows(ex *Exercise, count int, at time.Time) bool {
+	if r.Count != 0 && count >= r.Count {
+		return false
+	}
+	if r.UntilOpEnd && !ex.OpEnd.IsZero() && at.After(ex.OpEnd) {
+		return false
+	}
+	if r.UntilTime {
+		var day = ex.OpStart
+		if day.IsZero() {
+			day = at
+		}
+		y, m, d := day.Date()
+		if at.After(time.Date(y, m, d, 0, 0, 0, 0, time.Local).Add(r.Until)) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsTriggeredBy returns whether the event is triggered by an event with the
+// specified type and name.  global indicates whether the triggering event is
+// global (i.e., not specific to a station).  Bulletin events are the only
+// events that can be triggered globally.  Bulletins triggered by start, at, or
+// other bulletins are triggered only globally; all others are triggered only
+// by station-specific events.  The event's condition, and its trigger station
+// or quorum, if any, are not checked.
+func (e *Event) IsTriggeredBy(ttype EventType, tname string, global bool) bool {
+	if e.TriggerOverdue || !e.HasTrigger(ttype, tname) {
+		return false
+	}
+	if e.Type != EventBulletin || e.CrossStation() {
+		return !global
+	}
+	switch e.TriggerType {
+	case EventStart, EventAt, EventBulletin:
+		return global
+	}
+	return !global
+}
+
+// IsTriggeredByOverdue returns whether the event is triggered by an event with
+// the specified type and name becoming overdue.  The event's condition, if any,
+// is not checked.
+func (e *Event) IsTriggeredByOverdue(ttype EventType, tname string) bool {
+	return e.TriggerOverdue && e.TriggerType == ttype && e.TriggerName == tname
+}
+
+// HasTrigger returns whether the event with the specified type and name is
+// the trigger, or one of the triggers, of the event.
+func (e *Event) HasTrigger(ttype EventType, tname string) bool {
+	if e.Triggers == nil {
+		return e.TriggerType == ttype && e.TriggerName == tname
+	}
+	return slices.Contains(e.Triggers, Trigger{ttype, tname})
+}
+
+// TriggerList returns the triggers of the event:  its Triggers, if it has more
+// than one, or else its single trigger.
+func (e *Event) TriggerList() []Trigger {
+	if e.Triggers == nil {
+		return []Trigger{{e.TriggerType, e.TriggerName}}
+	}
+	return e.Triggers
+}
+
+// CrossStation returns whether the event's trigger depends on what has
+// happened at other stations, i.e., whether it has a trigger station or
+// quorum.  Such events happen for every station when triggered.
+func (e *Event) CrossStation() bool {
+	return e.TriggerStation != "" || e.TriggerQuorum != 0
+}
+
+// Quorum returns the number of stations for which the triggering event must
+// have happened in order to trigger the event, given the number of stations
+// in the exercise.  It returns zero if the event has no quorum.
+func (e *Event) Quorum(nstations int) int {
+	if !e.TriggerPercent {
+		return e.TriggerQuorum
+	}
+	return max((e.TriggerQuorum*nstations+99)/100, 1)
+}
+
+// TriggerFrom returns the "from" qualifier on the event's trigger, as it would
+// be written in the definition, or an empty string if it has none.
+func (e *Event) TriggerFrom() string {
+	switch {
+	case e.TriggerStation != "":
+		return e.TriggerStation
+	case e.TriggerPercent:
+		return fmt.Sprintf("%d%% of stations", e.TriggerQuorum)
+	case e.TriggerQuorum == 1:
+		return "1 station"
+	case e.TriggerQuorum != 0:
+		return fmt.Sprintf("%d stations", e.TriggerQuorum)
+	}
+	return ""
+}
+
+// ConditionMet returns whether the event's trigger condition, if any, is met.
+// lookup is called to get the value of each variable in the condition; it
+// returns false if the variable has no value, in which case any comparison
+// involving it is false.
+func (e *Event) ConditionMet(lookup func(vname string) (string, bool)) bool {
+	if e.Condition == nil {
+		return true
+	}
+	return e.Condition.Met(lookup)
 }
 
 type MatchReceive struct {
@@ -128,6 +322,12 @@ type MatchReceive struct {
 	Type      string
 	Subject   string
 	SubjectRE *regexp.Regexp
+	// SubjectREText is the subjectRE as written in the definition.
+	// SubjectRE is compiled from it, anchored and case-insensitive.
+	SubjectREText string
+	// Feedback is the feedback setting for the message; see
+	// Definition.Feedback.  It is empty if not specified.
+	Feedback string
 }
 
 func (mr *MatchReceive) hiddenBy(o *MatchReceive) bool {
@@ -143,6 +343,23 @@ func (mr *MatchReceive) hiddenBy(o *MatchReceive) bool {
 	return false
 }
 
+// Rejects describes the replies the engine sends to the senders of messages it
+// rejects.  The templates can use the variables reject.subject (the subject line
+// of the rejected message) and reject.from (its sender's address), as well as
+// exercise and now variables.
+type Rejects struct {
+	// Silent indicates that rejected messages get no reply.
+	Silent bool
+	// SenderSubject and SenderBody are the subject and body of the reply
+	// to a message from an unknown sender.
+	SenderSubject StringWithInterps
+	SenderBody    StringWithInterps
+	// MessageSubject and MessageBody are the subject and body of the reply
+	// to a message that didn't match any expected message.
+	MessageSubject StringWithInterps
+	MessageBody    StringWithInterps
+}
+
 type Bulletin struct {
 	Area    string
 	Subject string
@@ -153,6 +370,18 @@ type Message struct {
 	Type    string
 	Version string
 	Fields  map[string]StringWithInterps
+	// Variant is the variant name of the message, from a [SEND Name/variant]
+	// section heading.  It is empty for a [SEND Name] section.
+	Variant string
+	// Variants lists the other variants of the message, in the order they
+	// appear in the definition.  The engine chooses one of them, or the
+	// message itself, at random each time the message is sent.
+	Variants []*Message
+}
+
+// AllVariants returns the message and all of its variants.
+func (m *Message) AllVariants() []*Message {
+	return append([]*Message{m}, m.Variants...)
 }
 
 // A StringWithInterps is a string that may contain interpolated variables.  It
@@ -183,8 +412,11 @@ var eventTypeNames = map[EventType]string{
 	EventSend:     "send",
 	EventDeliver:  "deliver",
 	EventAlert:    "alert",
+	EventSet:      "set",
 	EventReceipt:  "receipt",
 	EventReject:   "reject",
+	EventFeedback: "feedback",
 	EventStart:    "start",
 	EventManual:   "manual",
+	EventAt:       "at",
 }
@@ -0,0 +1,131 @@
+package definition
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Lint looks for things in the exercise definition that are legal but
+// probably mistakes, and returns a warning for each.  It should only be called
+// on a definition that was read without errors.
+func (def *Definition) Lint() (warnings []string) {
+	var reachable = def.reachableEvents()
+
+	// Events that can never be triggered.
+	for _, e := range def.Events {
+		if !reachable[e] {
+			warnings = append(warnings, fmt.Sprintf("[EVENTS] %s %s can never be triggered (it is not reachable from start, manual, or at)", e.Type, e.Name))
+		}
+	}
+	// Trigger times outside of the exercise.
+	for _, e := range def.Events {
+		if e.TriggerType != EventAt {
+			continue
+		}
+		if e.TriggerTime.Before(def.Exercise.OpStart) {
+			warnings = append(warnings, fmt.Sprintf("[EVENTS] %s %s is triggered at %s, before opstart", e.Type, e.Name, e.TriggerName))
+		} else if !def.Exercise.OpEnd.IsZero() && e.TriggerTime.After(def.Exercise.OpEnd) {
+			warnings = append(warnings, fmt.Sprintf("[EVENTS] %s %s is triggered at %s, after opend", e.Type, e.Name, e.TriggerName))
+		}
+	}
+	// React durations shorter than delays.
+	for _, e := range def.Events {
+		if e.React != 0 && e.React < e.Delay {
+			warnings = append(warnings, fmt.Sprintf("[EVENTS] %s %s has react %s shorter than delay %s", e.Type, e.Name, e.React, e.Delay))
+		}
+	}
+	// RECEIVE sections that can never be matched.
+	for _, name := range slices.Sorted(maps.Keys(def.Receive)) {
+		if !slices.ContainsFunc(def.MatchReceive, func(mr *MatchReceive) bool { return mr.Name == name }) {
+			warnings = append(warnings, fmt.Sprintf("[RECEIVE %s] has no [MATCH RECEIVE] row, so it will never be matched by a received message", name))
+		}
+	}
+	// Condition variables that are never set.
+	for _, e := range def.Events {
+		if e.Condition == nil {
+			continue
+		}
+		for _, vname := range e.Condition.Variables() {
+			if w := def.lintVariable(vname, reachable); w != "" {
+				warnings = append(warnings, fmt.Sprintf("[EVENTS] %s %s condition: %s", e.Type, e.Name, w))
+			}
+		}
+	}
+	return warnings
+}
+
+// reachableEvents returns the set of events that can be triggered, directly or
+// indirectly, by the exercise start, by a manual trigger, or at a set time.
+func (def *Definition) reachableEvents() (reachable map[*Event]bool) {
+	reachable = make(map[*Event]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, e := range def.Events {
+			if reachable[e] {
+				continue
+			}
+			if e.TriggerType == EventStart || e.TriggerType == EventManual || e.TriggerType == EventAt ||
+				def.triggersReachable(e, reachable) {
+				reachable[e] = true
+				changed = true
+			}
+		}
+	}
+	return reachable
+}
+
+// triggersReachable returns whether the events that trigger e are reachable:
+// all of them if it needs all of its triggers, or else any of them.
+func (def *Definition) triggersReachable(e *Event, reachable map[*Event]bool) bool {
+	var count int
+	for _, t := range e.TriggerList() {
+		if reachable[def.Event(t.Type, t.Name)] {
+			count++
+		}
+	}
+	if e.AllTriggers {
+		return count == len(e.Triggers)
+	}
+	return count != 0
+}
+
+// lintVariable checks whether a variable used in a condition will ever have a
+// value.  It returns a warning if not, or an empty string if so.
+func (def *Definition) lintVariable(vname string, reachable map[*Event]bool) string {
+	group, item, _ := strings.Cut(vname, ".")
+	if slices.ContainsFunc(def.Events, func(e *Event) bool {
+		return e.Type == EventSet && e.Variable == vname && reachable[e]
+	}) {
+		return "" // set during the exercise
+	}
+	switch group {
+	case "exercise":
+		if def.Exercise.Variables[item] == "" {
+			return fmt.Sprintf("%s is never set", vname)
+		}
+	case "station":
+		var blank []string
+		for _, stn := range def.Stations {
+			if stn.Variables[item] == "" {
+				blank = append(blank, stn.CallSign)
+			}
+		}
+		if len(blank) == len(def.Stations) {
+			return fmt.Sprintf("%s is never set", vname)
+		}
+		if len(blank) != 0 {
+			return fmt.Sprintf("%s is not set for %s", vname, strings.Join(blank, ", "))
+		}
+	case "now", "trigger":
+		break
+	default:
+		if !slices.ContainsFunc(def.Events, func(e *Event) bool {
+			return e.Name == group && reachable[e] && (e.Type == EventReceive || e.Type == EventSend || e.Type == EventInject)
+		}) {
+			return fmt.Sprintf("%s is never set (message %s is never sent or received)", vname, group)
+		}
+	}
+	return ""
+}
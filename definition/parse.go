@@ -21,13 +21,14 @@ var (
 	prefixRE  = regexp.MustCompile(`^(?:[A-Z][A-Z0-9]{2}|[0-9][A-Z]{2})$`)
 	taccallRE = regexp.MustCompile(`^[A-Z][A-Z0-9]{3,}$`)
 	msgnameRE = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	variantRE = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
 )
 
 func (def *Definition) parseExercise(table [][]string, start int) (err error) {
 	if def.Exercise != nil {
 		return fmt.Errorf("%d: already have an [EXERCISE] section", start-1)
 	}
-	def.Exercise = &Exercise{Variables: make(map[string]string)}
+	def.Exercise = &Exercise{FuzzyMatch: "suggest", Variables: make(map[string]string)}
 	for lnum, line := range table {
 		if line == nil {
 			continue
@@ -108,6 +109,16 @@ func (def *Definition) parseExercise(table [][]string, start int) (err error) {
 				return fmt.Errorf("%d: startmsgid is not a valid XXX-###P message ID", lnum+start)
 			}
 			def.Exercise.StartMsgID = line[1]
+		case "feedback":
+			if !validFeedback(line[1]) {
+				return fmt.Errorf("%d: feedback must be \"none\", \"problems\", or \"score\"", lnum+start)
+			}
+			def.Exercise.Feedback = line[1]
+		case "fuzzymatch":
+			if line[1] != "off" && line[1] != "suggest" && line[1] != "auto" {
+				return fmt.Errorf("%d: fuzzymatch must be \"off\", \"suggest\", or \"auto\"", lnum+start)
+			}
+			def.Exercise.FuzzyMatch = line[1]
 		}
 		def.Exercise.Variables[line[0]] = line[1]
 	}
@@ -230,7 +241,7 @@ func (def *Definition) parseStations(table [][]string, start int) (err error) {
 			receiptcol = i
 		default:
 			if !ascii(col) {
-				return fmt.Errorf("%d: column %d name is not ASCII", start+1, i+1)
+				return fmt.Errorf("%d: column %d name is not ASCII", start, i+1)
 			}
 		}
 	}
@@ -238,6 +249,9 @@ func (def *Definition) parseStations(table [][]string, start int) (err error) {
 		return fmt.Errorf("%d: table must contain column \"callsign\"", start)
 	}
 	for lnum, line := range table[1:] {
+		if line == nil {
+			continue
+		}
 		for i, col := range line {
 			if !ascii(col) {
 				return fmt.Errorf("%d: %s value is not ASCII", lnum+start+1, table[0][i])
@@ -293,7 +307,7 @@ func (def *Definition) parseStations(table [][]string, start int) (err error) {
 	return nil
 }
 
-var conditionRE = regexp.MustCompile(`^((?:exercise|station)\.[A-Za-z][-A-Za-z0-9_]*|[A-Za-z][A-Za-z0-9_]*\.(?:msgid|subjectline))\s*(=|!=|<|<=|>|>=|≈)\s*(\S.*)$`)
+var setVariableRE = regexp.MustCompile(`^(?:exercise|station)\.[A-Za-z][-A-Za-z0-9_]*$`)
 
 func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 	if def.Events != nil {
@@ -302,7 +316,7 @@ func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 	if len(table) == 0 || table[0] == nil {
 		return fmt.Errorf("%d: table must begin with column headings", start)
 	}
-	var groupcol, typecol, namecol, triggercol, delaycol, reactcol, conditioncol = -1, -1, -1, -1, -1, -1, -1
+	var groupcol, typecol, namecol, triggercol, delaycol, reactcol, conditioncol, valuecol, repeatcol = -1, -1, -1, -1, -1, -1, -1, -1, -1
 	for i, col := range table[0] {
 		switch col {
 		case "group":
@@ -319,6 +333,10 @@ func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 			reactcol = i
 		case "condition":
 			conditioncol = i
+		case "value":
+			valuecol = i
+		case "repeat":
+			repeatcol = i
 		default:
 			return fmt.Errorf("%d: unknown column %q", start, col)
 		}
@@ -328,7 +346,8 @@ func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 	}
 	for lnum, line := range table[1:] {
 		for i, col := range line {
-			if !ascii(col) {
+			// Conditions and values can contain ≈ and chevrons.
+			if i != conditioncol && i != valuecol && i != delaycol && !ascii(col) {
 				return fmt.Errorf("%d: %s value is not ASCII", lnum+start+1, table[0][i])
 			}
 		}
@@ -349,6 +368,8 @@ func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 			event.Type = EventDeliver
 		case "alert":
 			event.Type = EventAlert
+		case "set":
+			event.Type = EventSet
 		default:
 			return fmt.Errorf("%d: invalid event type %q", lnum+start+1, line[typecol])
 		}
@@ -362,7 +383,14 @@ func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 		}) {
 			return fmt.Errorf("%d: multiple lines for %s %q", lnum+start+1, eventTypeNames[event.Type], event.Name)
 		}
-		switch line[triggercol] {
+		var trigger = line[triggercol]
+		if name, from, ok := strings.Cut(trigger, " from "); ok {
+			if !parseTriggerFrom(strings.TrimSpace(from), &event) {
+				return fmt.Errorf("%d: invalid trigger %q: \"from\" must be followed by a station call sign, \"N stations\", or \"N%% of stations\"", lnum+start+1, line[triggercol])
+			}
+			trigger = strings.TrimSpace(name)
+		}
+		switch trigger {
 		case "":
 			if len(def.Events) == 0 || def.Events[len(def.Events)-1] == nil {
 				return fmt.Errorf("%d: trigger is required when there is no previous line", lnum+start+1)
@@ -373,49 +401,95 @@ func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 		case "manual":
 			event.TriggerType = EventManual
 		default:
-			if strings.HasPrefix(line[triggercol], "inject ") {
-				event.TriggerType, event.TriggerName = EventInject, line[triggercol][7:]
-			} else if strings.HasPrefix(line[triggercol], "receive ") {
-				event.TriggerType, event.TriggerName = EventReceive, line[triggercol][8:]
-			} else if strings.HasPrefix(line[triggercol], "send ") {
-				event.TriggerType, event.TriggerName = EventSend, line[triggercol][5:]
-			} else if strings.HasPrefix(line[triggercol], "bulletin ") {
-				event.TriggerType, event.TriggerName = EventBulletin, line[triggercol][9:]
-			} else if strings.HasPrefix(line[triggercol], "deliver ") {
-				event.TriggerType, event.TriggerName = EventDeliver, line[triggercol][8:]
-			} else if strings.HasPrefix(line[triggercol], "alert ") {
-				event.TriggerType, event.TriggerName = EventAlert, line[triggercol][6:]
-			} else {
-				return fmt.Errorf("%d: invalid trigger %q", lnum+start+1, line[triggercol])
+			var sep string
+			if strings.Contains(trigger, " + ") {
+				sep, event.AllTriggers = " + ", true
+			}
+			if strings.Contains(trigger, " | ") {
+				if sep != "" {
+					return fmt.Errorf("%d: invalid trigger %q: cannot combine \"+\" and \"|\"", lnum+start+1, line[triggercol])
+				}
+				sep = " | "
+			}
+			if sep == "" {
+				if rest, ok := strings.CutPrefix(trigger, "overdue "); ok {
+					trigger, event.TriggerOverdue = strings.TrimSpace(rest), true
+				}
+				if event.TriggerType, event.TriggerName, err = parseTrigger(trigger); err != nil {
+					return fmt.Errorf("%d: %s", lnum+start+1, err)
+				}
+				if event.TriggerOverdue {
+					switch event.TriggerType {
+					case EventAlert, EventDeliver, EventReceive:
+						break
+					default:
+						return fmt.Errorf("%d: invalid trigger %q: only alert, deliver, and receive events can be overdue", lnum+start+1, line[triggercol])
+					}
+					if event.CrossStation() {
+						return fmt.Errorf("%d: invalid trigger %q: \"from\" cannot be used with overdue triggers", lnum+start+1, line[triggercol])
+					}
+				}
+				break
 			}
-			if !msgnameRE.MatchString(event.TriggerName) {
-				return fmt.Errorf("%d: invalid trigger %q", lnum+start+1, line[triggercol])
+			for _, part := range strings.Split(trigger, sep) {
+				var t Trigger
+				if part = strings.TrimSpace(part); part == "start" || part == "manual" || strings.HasPrefix(part, "overdue ") {
+					return fmt.Errorf("%d: invalid trigger %q: only triggers on other events can be combined", lnum+start+1, line[triggercol])
+				}
+				if t.Type, t.Name, err = parseTrigger(part); err != nil {
+					return fmt.Errorf("%d: %s", lnum+start+1, err)
+				}
+				if t.Type == EventAt {
+					return fmt.Errorf("%d: invalid trigger %q: only triggers on other events can be combined", lnum+start+1, line[triggercol])
+				}
+				if slices.Contains(event.Triggers, t) {
+					return fmt.Errorf("%d: invalid trigger %q: %s %s is listed twice", lnum+start+1, line[triggercol], eventTypeNames[t.Type], t.Name)
+				}
+				event.Triggers = append(event.Triggers, t)
+			}
+			event.TriggerType, event.TriggerName = event.Triggers[0].Type, event.Triggers[0].Name
+			if event.Type == EventBulletin {
+				return fmt.Errorf("%d: bulletins cannot have multiple triggers", lnum+start+1)
+			}
+			if event.CrossStation() {
+				return fmt.Errorf("%d: invalid trigger %q: \"from\" cannot be used with multiple triggers", lnum+start+1, line[triggercol])
 			}
 		}
-		if event.Type == EventBulletin && event.TriggerType != EventStart && event.TriggerType != EventManual {
-			return fmt.Errorf("%d: bulletins can only be triggered by start or manual", lnum+start+1)
+		if event.CrossStation() {
+			switch event.TriggerType {
+			case EventManual, EventAt, EventBulletin:
+				return fmt.Errorf("%d: invalid trigger %q: \"from\" cannot be used with %s triggers", lnum+start+1, line[triggercol], eventTypeNames[event.TriggerType])
+			}
 		}
-		if delaycol != -1 {
-			if d, err := time.ParseDuration(line[delaycol]); err != nil && line[delaycol] != "" {
-				return fmt.Errorf("%d: invalid delay %q", lnum+start+1, line[delaycol])
-			} else {
-				event.Delay = d
+		if delaycol != -1 && line[delaycol] != "" {
+			if event.Delay, event.DelayMax, err = parseDelay(line[delaycol]); err != nil {
+				return fmt.Errorf("%d: invalid delay %q: %s", lnum+start+1, line[delaycol], err)
 			}
 		}
 		if conditioncol != -1 && line[conditioncol] != "" {
-			if match := conditionRE.FindStringSubmatch(line[conditioncol]); match == nil {
-				return fmt.Errorf("%d: syntax error in condition", lnum+start+1)
+			if cond, err := parseCondition(line[conditioncol]); err != nil {
+				return fmt.Errorf("%d: syntax error in condition: %s", lnum+start+1, err)
 			} else {
-				event.ConditionVar, event.ConditionOp = match[1], match[2]
-				if event.ConditionOp == "≈" {
-					if re, err := regexp.Compile(match[3]); err != nil {
-						return fmt.Errorf("%d: syntax error in condition regular expression", lnum+start+1)
-					} else {
-						event.ConditionRE = re
-					}
-				} else {
-					event.ConditionVal = match[3]
-				}
+				event.Condition = cond
+			}
+		}
+		if valuecol != -1 && line[valuecol] != "" {
+			if event.Type != EventSet {
+				return fmt.Errorf("%d: only set events can have a value", lnum+start+1)
+			}
+			if vname, value, ok := strings.Cut(line[valuecol], "="); !ok {
+				return fmt.Errorf("%d: value must have the form \"variable = value\"", lnum+start+1)
+			} else if event.Variable = strings.TrimSpace(vname); !setVariableRE.MatchString(event.Variable) {
+				return fmt.Errorf("%d: only exercise.XXX and station.XXX variables can be set", lnum+start+1)
+			} else if event.Value, err = parseStringWithInterps(strings.TrimSpace(value), ascii); err != nil {
+				return fmt.Errorf("%d: value: %s", lnum+start+1, err)
+			}
+		} else if event.Type == EventSet {
+			return fmt.Errorf("%d: set events must have a value", lnum+start+1)
+		}
+		if repeatcol != -1 && line[repeatcol] != "" {
+			if event.Repeat, err = parseRepeat(line[repeatcol]); err != nil {
+				return fmt.Errorf("%d: invalid repeat %q: %s", lnum+start+1, line[repeatcol], err)
 			}
 		}
 		def.Events = append(def.Events, &event)
@@ -425,14 +499,20 @@ func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 			} else if d, err := time.ParseDuration(line[reactcol]); err != nil {
 				return fmt.Errorf("%d: invalid react %q", lnum+start+1, line[reactcol])
 			} else if event.Type == EventInject {
+				event.React = d
 				var event2 = event
 				event2.TriggerType, event2.TriggerName = event.Type, event.Name
-				event2.Type, event2.Delay = EventReceive, d
+				event2.TriggerStation, event2.TriggerQuorum, event2.TriggerPercent = "", 0, false
+				event2.Triggers, event2.AllTriggers, event2.TriggerOverdue = nil, false, false
+				event2.Type, event2.Delay, event2.DelayMax, event2.React, event2.Repeat = EventReceive, d, 0, 0, nil
 				def.Events = append(def.Events, &event2)
 			} else if event.Type == EventBulletin || event.Type == EventSend {
+				event.React = d
 				var event2 = event
 				event2.TriggerType, event2.TriggerName = event.Type, event.Name
-				event2.Type, event2.Delay = EventDeliver, d
+				event2.TriggerStation, event2.TriggerQuorum, event2.TriggerPercent = "", 0, false
+				event2.Triggers, event2.AllTriggers, event2.TriggerOverdue = nil, false, false
+				event2.Type, event2.Delay, event2.DelayMax, event2.React, event2.Repeat = EventDeliver, d, 0, 0, nil
 				def.Events = append(def.Events, &event2)
 			} else if line[reactcol] != "" {
 				return fmt.Errorf("%d: %s events do not support react values", lnum+start+1, eventTypeNames[event.Type])
@@ -440,17 +520,162 @@ func (def *Definition) parseEvents(table [][]string, start int) (err error) {
 		}
 	}
 	for _, e := range def.Events {
-		if e != nil && e.TriggerName != "" {
-			if !slices.ContainsFunc(def.Events, func(e2 *Event) bool {
-				return e2 != nil && e2.Type == e.TriggerType && e2.Name == e.TriggerName
+		if e == nil {
+			continue
+		}
+		for _, t := range e.TriggerList() {
+			if t.Name != "" && t.Type != EventAt && !slices.ContainsFunc(def.Events, func(e2 *Event) bool {
+				return e2 != nil && e2.Type == t.Type && e2.Name == t.Name
 			}) {
-				return fmt.Errorf("%d: %s %s is triggered by nonexistent event %s %s", start-1, eventTypeNames[e.Type], e.Name, eventTypeNames[e.TriggerType], e.TriggerName)
+				return fmt.Errorf("%d: %s %s is triggered by nonexistent event %s %s", start-1, eventTypeNames[e.Type], e.Name, eventTypeNames[t.Type], t.Name)
 			}
 		}
 	}
 	return nil
 }
 
+// parseTrigger parses a trigger on a previous event (its type and message
+// name) or an "at" trigger.  ("start", "manual", and empty triggers are handled
+// by the caller.)
+func parseTrigger(s string) (ttype EventType, tname string, err error) {
+	if strings.HasPrefix(s, "inject ") {
+		ttype, tname = EventInject, s[7:]
+	} else if strings.HasPrefix(s, "receive ") {
+		ttype, tname = EventReceive, s[8:]
+	} else if strings.HasPrefix(s, "send ") {
+		ttype, tname = EventSend, s[5:]
+	} else if strings.HasPrefix(s, "bulletin ") {
+		ttype, tname = EventBulletin, s[9:]
+	} else if strings.HasPrefix(s, "deliver ") {
+		ttype, tname = EventDeliver, s[8:]
+	} else if strings.HasPrefix(s, "alert ") {
+		ttype, tname = EventAlert, s[6:]
+	} else if strings.HasPrefix(s, "set ") {
+		ttype, tname = EventSet, s[4:]
+	} else if strings.HasPrefix(s, "at ") {
+		ttype, tname = EventAt, strings.TrimSpace(s[3:])
+	} else {
+		return 0, "", fmt.Errorf("invalid trigger %q", s)
+	}
+	if ttype == EventAt {
+		if _, _, ok := parseTriggerTime(tname); !ok {
+			return 0, "", fmt.Errorf("invalid trigger %q: time must be HH:MM or opstart+DURATION", s)
+		}
+	} else if !msgnameRE.MatchString(tname) {
+		return 0, "", fmt.Errorf("invalid trigger %q", s)
+	}
+	return ttype, tname, nil
+}
+
+// parseTriggerTime parses the time of an "at" trigger, which is either a time
+// of day (HH:MM) on the day of opstart, or "opstart+" followed by a duration.
+// It returns the time of day or the offset from opstart, respectively, and
+// whether the offset is from opstart.
+func parseTriggerTime(s string) (offset time.Duration, fromStart, ok bool) {
+	if rest, found := strings.CutPrefix(s, "opstart+"); found {
+		if d, err := time.ParseDuration(rest); err == nil && d >= 0 {
+			return d, true, true
+		}
+		return 0, false, false
+	}
+	if t, err := time.Parse("15:04", s); err == nil {
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, false, true
+	}
+	return 0, false, false
+}
+
+var triggerQuorumRE = regexp.MustCompile(`^([1-9][0-9]*)(?: stations?|(%) of stations)$`)
+
+// parseTriggerFrom parses the qualifier following "from" in a trigger, which
+// is a station call sign, "N stations", or "N% of stations", and records it in
+// the event.  It returns false if the qualifier is invalid.
+func parseTriggerFrom(s string, event *Event) bool {
+	if match := triggerQuorumRE.FindStringSubmatch(s); match != nil {
+		event.TriggerQuorum, _ = strconv.Atoi(match[1])
+		event.TriggerPercent = match[2] != ""
+		return !event.TriggerPercent || event.TriggerQuorum <= 100
+	}
+	if fcccallRE.MatchString(s) || taccallRE.MatchString(s) {
+		event.TriggerStation = s
+		return true
+	}
+	return false
+}
+
+// parseRepeat parses the repeat column of an event.  It contains an interval,
+// optionally followed by "xN" to limit the number of instances to N, and/or by
+// "until HH:MM" or "until opend" to limit the time of the last instance.  With
+// neither limit, the event repeats until opend.
+func parseRepeat(s string) (r *Repeat, err error) {
+	var fields = strings.Fields(s)
+
+	r = new(Repeat)
+	if r.Interval, err = time.ParseDuration(fields[0]); err != nil || r.Interval <= 0 {
+		return nil, errors.New("interval must be a positive duration")
+	}
+	for fields = fields[1:]; len(fields) != 0; fields = fields[1:] {
+		switch {
+		case fields[0] == "until" && len(fields) > 1 && !r.UntilTime && !r.UntilOpEnd:
+			if fields[1] == "opend" {
+				r.UntilOpEnd = true
+			} else if t, err := time.Parse("15:04", fields[1]); err == nil {
+				r.Until = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+				r.UntilTime = true
+			} else {
+				return nil, errors.New(`"until" must be followed by HH:MM or "opend"`)
+			}
+			fields = fields[1:]
+		case fields[0][0] == 'x' && r.Count == 0:
+			if r.Count, err = strconv.Atoi(fields[0][1:]); err != nil || r.Count < 2 {
+				return nil, errors.New("count must be 2 or more")
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q", fields[0])
+		}
+	}
+	if r.Count == 0 && !r.UntilTime && !r.UntilOpEnd {
+		r.UntilOpEnd = true
+	}
+	return r, nil
+}
+
+// parseDelay parses the delay column of an event.  It contains a duration, a
+// duration with a jitter ("5m±2m"), or a random range ("rand(3m,10m)").  For
+// the latter two, it returns the shortest and longest delays; otherwise,
+// delayMax is zero.
+func parseDelay(s string) (delay, delayMax time.Duration, err error) {
+	if base, jitter, ok := strings.Cut(s, "±"); ok {
+		var j time.Duration
+		if delay, err = time.ParseDuration(strings.TrimSpace(base)); err != nil || delay < 0 {
+			return 0, 0, errors.New("delay must be a non-negative duration")
+		}
+		if j, err = time.ParseDuration(strings.TrimSpace(jitter)); err != nil || j <= 0 {
+			return 0, 0, errors.New("jitter must be a positive duration")
+		}
+		return max(delay-j, 0), delay + j, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "rand("); ok {
+		if rest, ok = strings.CutSuffix(rest, ")"); !ok {
+			return 0, 0, errors.New(`missing ")"`)
+		}
+		lo, hi, ok := strings.Cut(rest, ",")
+		if !ok {
+			return 0, 0, errors.New(`"rand" needs a shortest and a longest delay`)
+		}
+		if delay, err = time.ParseDuration(strings.TrimSpace(lo)); err != nil || delay < 0 {
+			return 0, 0, errors.New("shortest delay must be a non-negative duration")
+		}
+		if delayMax, err = time.ParseDuration(strings.TrimSpace(hi)); err != nil || delayMax <= delay {
+			return 0, 0, errors.New("longest delay must be a duration longer than the shortest")
+		}
+		return delay, delayMax, nil
+	}
+	if delay, err = time.ParseDuration(s); err != nil || delay < 0 {
+		return 0, 0, errors.New("delay must be a non-negative duration")
+	}
+	return delay, 0, nil
+}
+
 func (def *Definition) parseMatchReceive(table [][]string, start int) (err error) {
 	if def.MatchReceive != nil {
 		return fmt.Errorf("%d: already have a [MATCH RECEIVE] section", start-1)
@@ -458,7 +683,7 @@ func (def *Definition) parseMatchReceive(table [][]string, start int) (err error
 	if len(table) == 0 || table[0] == nil {
 		return fmt.Errorf("%d: table must begin with column headings", start)
 	}
-	var namecol, typecol, subjectcol, subjectrecol = -1, -1, -1, -1
+	var namecol, typecol, subjectcol, subjectrecol, feedbackcol = -1, -1, -1, -1, -1
 	for i, col := range table[0] {
 		switch col {
 		case "name":
@@ -469,6 +694,8 @@ func (def *Definition) parseMatchReceive(table [][]string, start int) (err error
 			subjectcol = i
 		case "subjectre", "subjectRE":
 			subjectrecol = i
+		case "feedback":
+			feedbackcol = i
 		default:
 			return fmt.Errorf("%d: unknown column %q", start, col)
 		}
@@ -512,8 +739,15 @@ func (def *Definition) parseMatchReceive(table [][]string, start int) (err error
 				if mr.SubjectRE, err = regexp.Compile("^(?i:" + restr + ")$"); err != nil {
 					return fmt.Errorf("%d: subjectRE value is not a valid regular expression", lnum+start+1)
 				}
+				mr.SubjectREText = restr
 			}
 		}
+		if feedbackcol != -1 && line[feedbackcol] != "" {
+			if !validFeedback(line[feedbackcol]) {
+				return fmt.Errorf("%d: feedback must be \"none\", \"problems\", or \"score\"", lnum+start+1)
+			}
+			mr.Feedback = line[feedbackcol]
+		}
 		if mr.Type == "" && mr.Subject == "" && mr.SubjectRE == nil {
 			return fmt.Errorf("%d: line for %s must have a type, a subject, and/or a subjectRE", lnum+start+1, mr.Name)
 		}
@@ -522,6 +756,79 @@ func (def *Definition) parseMatchReceive(table [][]string, start int) (err error
 	return nil
 }
 
+// validFeedback returns whether s is a valid feedback setting.
+func validFeedback(s string) bool {
+	return s == "none" || s == "problems" || s == "score"
+}
+
+// The default reject replies.
+const (
+	defaultRejectSubject = "REJECT: «reject.subject»"
+	defaultSenderBody    = `«exercise.myname» received a message from you with
+  Subject: «reject.subject»
+The mailbox you sent this message from does not correspond to any station
+participating in the current exercise.  Please make sure you are sending from
+the correct mailbox (e.g., your assigned tactical callsign, not your personal
+FCC callsign).  If you cannot find the problem, ask for help from the exercise
+manager.`
+	defaultMessageBody = `«exercise.myname» received a message from you with
+  Subject: «reject.subject»
+This subject line does not match any of the messages the exercise automation
+was expecting to receive.  Please check the subject line and try again.  If you
+cannot find the problem, ask for help from the exercise manager.`
+)
+
+// defaultRejects returns the reject replies used when the definition doesn't
+// have a [REJECTS] section, or doesn't override them in it.
+func defaultRejects() *Rejects {
+	var r Rejects
+	r.SenderSubject, _ = parseStringWithInterps(defaultRejectSubject, ascii)
+	r.SenderBody, _ = parseStringWithInterps(defaultSenderBody, ascii)
+	r.MessageSubject, _ = parseStringWithInterps(defaultRejectSubject, ascii)
+	r.MessageBody, _ = parseStringWithInterps(defaultMessageBody, ascii)
+	return &r
+}
+
+func (def *Definition) parseRejects(table [][]string, start int) (err error) {
+	if def.Rejects != nil {
+		return fmt.Errorf("%d: already have a [REJECTS] section", start-1)
+	}
+	def.Rejects = defaultRejects()
+	for lnum, line := range table {
+		var target *StringWithInterps
+
+		if line == nil {
+			continue
+		}
+		switch line[0] {
+		case "autoreply":
+			switch line[1] {
+			case "yes":
+				def.Rejects.Silent = false
+			case "no":
+				def.Rejects.Silent = true
+			default:
+				return fmt.Errorf("%d: autoreply must be \"yes\" or \"no\"", lnum+start)
+			}
+			continue
+		case "sendersubject":
+			target = &def.Rejects.SenderSubject
+		case "senderbody":
+			target = &def.Rejects.SenderBody
+		case "messagesubject":
+			target = &def.Rejects.MessageSubject
+		case "messagebody":
+			target = &def.Rejects.MessageBody
+		default:
+			return fmt.Errorf("%d: unknown key %q", lnum+start, line[0])
+		}
+		if *target, err = parseStringWithInterps(line[1], ascii); err != nil {
+			return fmt.Errorf("%d: %s value: %s", lnum+start, line[0], err)
+		}
+	}
+	return nil
+}
+
 func (def *Definition) parseBulletin(name string, table [][]string, start int) (err error) {
 	if !msgnameRE.MatchString(name) {
 		return fmt.Errorf("%d: invalid message name", start-1)
@@ -565,13 +872,22 @@ func (def *Definition) parseBulletin(name string, table [][]string, start int) (
 }
 
 func (def *Definition) parseSend(name string, table [][]string, start int) (err error) {
+	var variant string
+	// A [SEND Name/variant] section gives a variant of the message.
+	name, variant, _ = strings.Cut(name, "/")
 	if !msgnameRE.MatchString(name) {
 		return fmt.Errorf("%d: invalid message name", start-1)
 	}
-	if _, ok := def.Send[name]; ok {
+	if variant != "" && !variantRE.MatchString(variant) {
+		return fmt.Errorf("%d: invalid variant name", start-1)
+	}
+	if have := def.Send[name]; have != nil && slices.ContainsFunc(have.AllVariants(), func(m *Message) bool { return m.Variant == variant }) {
+		if variant != "" {
+			return fmt.Errorf("%d: already have a [SEND %s/%s] section", start-1, name, variant)
+		}
 		return fmt.Errorf("%d: already have a [SEND %s] section", start-1, name)
 	}
-	var m = Message{Fields: make(map[string]StringWithInterps)}
+	var m = Message{Fields: make(map[string]StringWithInterps), Variant: variant}
 	var blank message.Message
 	for lnum, line := range table {
 		if line == nil {
@@ -612,7 +928,14 @@ func (def *Definition) parseSend(name string, table [][]string, start int) (err
 			return fmt.Errorf("%d: %s messages do not have a %q field", start, m.Type, fname)
 		}
 	}
-	def.Send[name] = &m
+	if have := def.Send[name]; have != nil {
+		if m.Type != have.Type || m.Version != have.Version {
+			return fmt.Errorf("%d: all variants of %s must have the same type and version", start, name)
+		}
+		have.Variants = append(have.Variants, &m)
+	} else {
+		def.Send[name] = &m
+	}
 	return nil
 }
 
@@ -675,7 +998,7 @@ func commaSplit(s string) (list []string) {
 	return list
 }
 
-var interpRE = regexp.MustCompile(`^((?:exercise|station)\.[A-Za-z][-a-zA-Z0-9_]*|[A-Za-z][A-Za-z0-9_]*\.(?:msgid|subjectline)|now\.(?:date|time|datetime))(?::(-?[0-9]+)(?::(-?[0-9]+))?)?([-+]\d[0-9dhm]+)?$`)
+var interpRE = regexp.MustCompile(`^((?:exercise|station)\.[A-Za-z][-a-zA-Z0-9_]*|now\.(?:date|time|datetime)|[A-Za-z][A-Za-z0-9_]*\.[^:«»]+?)(?::(-?[0-9]+)(?::(-?[0-9]+))?)?( *[-+]\d[0-9dhm]*)?$`)
 
 func parseStringWithInterps(s string, checkASCII func(string) bool) (swi StringWithInterps, err error) {
 	for {
@@ -699,19 +1022,29 @@ func parseStringWithInterps(s string, checkASCII func(string) bool) (swi StringW
 		if match := interpRE.FindStringSubmatch(s[:idx]); match == nil {
 			return swi, errors.New("syntax error in variable interpolation")
 		} else {
-			swi.Variables = append(swi.Variables, match[1])
+			var variable, addition = match[1], strings.TrimLeft(match[4], " ")
+			// A message field label can end in a hyphen and digits
+			// (e.g. "Qty-1"), so a number with no unit right after
+			// one is part of the label.  To add or subtract it, it
+			// must be separated from the label by a space.
+			group, _, _ := strings.Cut(variable, ".")
+			if group != "exercise" && group != "station" && group != "now" &&
+				match[2] == "" && addition != "" && addition == match[4] && !strings.ContainsAny(addition, "dhm") {
+				variable, addition = variable+addition, ""
+			}
+			swi.Variables = append(swi.Variables, variable)
 			val, _ := strconv.Atoi(match[2])
 			swi.StartOffsets = append(swi.StartOffsets, val)
 			val, _ = strconv.Atoi(match[3])
 			swi.EndOffsets = append(swi.EndOffsets, val)
-			if match[4] != "" {
-				if _, err := strconv.Atoi(match[4][1:]); err != nil {
-					if _, ok := ParseDuration(match[4]); !ok {
+			if addition != "" {
+				if _, err := strconv.Atoi(addition[1:]); err != nil {
+					if _, ok := ParseDuration(addition); !ok {
 						return swi, errors.New("syntax error in variable interpolation")
 					}
 				}
 			}
-			swi.Additions = append(swi.Additions, match[4])
+			swi.Additions = append(swi.Additions, addition)
 		}
 		s = s[idx+2:]
 	}
@@ -0,0 +1,152 @@
+package definition
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRepeat(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Repeat
+		wantErr string
+	}{
+		{s: "15m", want: Repeat{Interval: 15 * time.Minute, UntilOpEnd: true}},
+		{s: "15m x3", want: Repeat{Interval: 15 * time.Minute, Count: 3}},
+		{s: "1h until 11:30", want: Repeat{Interval: time.Hour, Until: 11*time.Hour + 30*time.Minute, UntilTime: true}},
+		{s: "1h until opend", want: Repeat{Interval: time.Hour, UntilOpEnd: true}},
+		{s: "10m x4 until 11:00", want: Repeat{Interval: 10 * time.Minute, Count: 4, Until: 11 * time.Hour, UntilTime: true}},
+		{s: "10m until opend x4", want: Repeat{Interval: 10 * time.Minute, Count: 4, UntilOpEnd: true}},
+		{s: "1h until 00:00", want: Repeat{Interval: time.Hour, UntilTime: true}},
+		{s: "0m", wantErr: "interval must be a positive duration"},
+		{s: "often", wantErr: "interval must be a positive duration"},
+		{s: "15m x1", wantErr: "count must be 2 or more"},
+		{s: "15m xmany", wantErr: "count must be 2 or more"},
+		{s: "15m until noon", wantErr: `"until" must be followed by HH:MM or "opend"`},
+		{s: "15m until", wantErr: `unexpected "until"`},
+		{s: "15m x2 x3", wantErr: `unexpected "x3"`},
+		{s: "15m until 11:00 until opend", wantErr: `unexpected "until"`},
+		{s: "15m until 00:00 until 11:00", wantErr: `unexpected "until"`},
+		{s: "15m forever", wantErr: `unexpected "forever"`},
+	}
+	for _, tt := range tests {
+		r, err := parseRepeat(tt.s)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseRepeat(%q): err = %v, want %q", tt.s, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRepeat(%q): unexpected error %v", tt.s, err)
+		} else if *r != tt.want {
+			t.Errorf("parseRepeat(%q) = %+v, want %+v", tt.s, *r, tt.want)
+		}
+	}
+}
+
+func TestParseTriggerTime(t *testing.T) {
+	tests := []struct {
+		s         string
+		offset    time.Duration
+		fromStart bool
+		ok        bool
+	}{
+		{"10:15", 10*time.Hour + 15*time.Minute, false, true},
+		{"00:00", 0, false, true},
+		{"opstart+45m", 45 * time.Minute, true, true},
+		{"opstart+1h30m", 90 * time.Minute, true, true},
+		{"opstart+0s", 0, true, true},
+		{"opstart+-5m", 0, false, false},
+		{"opstart+", 0, false, false},
+		{"opstart", 0, false, false},
+		{"25:00", 0, false, false},
+		{"10:15am", 0, false, false},
+		{"", 0, false, false},
+	}
+	for _, tt := range tests {
+		offset, fromStart, ok := parseTriggerTime(tt.s)
+		if offset != tt.offset || fromStart != tt.fromStart || ok != tt.ok {
+			t.Errorf("parseTriggerTime(%q) = %s, %v, %v; want %s, %v, %v",
+				tt.s, offset, fromStart, ok, tt.offset, tt.fromStart, tt.ok)
+		}
+	}
+}
+
+func TestParseDelay(t *testing.T) {
+	tests := []struct {
+		s               string
+		delay, delayMax time.Duration
+		wantErr         string
+	}{
+		{s: "0", delay: 0},
+		{s: "15m", delay: 15 * time.Minute},
+		{s: "5m±2m", delay: 3 * time.Minute, delayMax: 7 * time.Minute},
+		{s: "5m ± 2m", delay: 3 * time.Minute, delayMax: 7 * time.Minute},
+		{s: "1m±2m", delay: 0, delayMax: 3 * time.Minute},
+		{s: "rand(3m,10m)", delay: 3 * time.Minute, delayMax: 10 * time.Minute},
+		{s: "rand(0, 1h)", delay: 0, delayMax: time.Hour},
+		{s: "-5m", wantErr: "delay must be a non-negative duration"},
+		{s: "soon", wantErr: "delay must be a non-negative duration"},
+		{s: "x±2m", wantErr: "delay must be a non-negative duration"},
+		{s: "5m±0s", wantErr: "jitter must be a positive duration"},
+		{s: "5m±", wantErr: "jitter must be a positive duration"},
+		{s: "rand(3m,10m", wantErr: `missing ")"`},
+		{s: "rand(3m)", wantErr: `"rand" needs a shortest and a longest delay`},
+		{s: "rand(-1m,10m)", wantErr: "shortest delay must be a non-negative duration"},
+		{s: "rand(10m,3m)", wantErr: "longest delay must be a duration longer than the shortest"},
+		{s: "rand(3m,3m)", wantErr: "longest delay must be a duration longer than the shortest"},
+	}
+	for _, tt := range tests {
+		delay, delayMax, err := parseDelay(tt.s)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseDelay(%q): err = %v, want %q", tt.s, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDelay(%q): unexpected error %v", tt.s, err)
+		} else if delay != tt.delay || delayMax != tt.delayMax {
+			t.Errorf("parseDelay(%q) = %s, %s; want %s, %s", tt.s, delay, delayMax, tt.delay, tt.delayMax)
+		}
+	}
+}
+
+func TestParseStringWithInterps(t *testing.T) {
+	tests := []struct {
+		s                  string
+		variable, addition string
+		startOff, endOff   int
+		wantErr            string
+	}{
+		{s: "«station.resends+1»", variable: "station.resends", addition: "+1"},
+		{s: "«CheckIn.time+1h30m»", variable: "CheckIn.time", addition: "+1h30m"},
+		{s: "«CheckIn.time-2d»", variable: "CheckIn.time", addition: "-2d"},
+		// A hyphen and digits after a field label are part of the label,
+		// unless separated from it by a space.
+		{s: "«Msg.Qty-1»", variable: "Msg.Qty-1"},
+		{s: "«Msg.Line 2-10»", variable: "Msg.Line 2-10"},
+		{s: "«Msg.Qty -1»", variable: "Msg.Qty", addition: "-1"},
+		{s: "«Msg.Qty +10»", variable: "Msg.Qty", addition: "+10"},
+		{s: "«Msg.Qty-1:0:2»", variable: "Msg.Qty-1", endOff: 2},
+		{s: "«Msg.Qty:1-1»", variable: "Msg.Qty", startOff: 1, addition: "-1"},
+		{s: "«Msg.Qty -1hh»", wantErr: "syntax error in variable interpolation"},
+	}
+	for _, tt := range tests {
+		swi, err := parseStringWithInterps(tt.s, ascii)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseStringWithInterps(%q): err = %v, want %q", tt.s, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseStringWithInterps(%q): unexpected error %v", tt.s, err)
+		} else if len(swi.Variables) != 1 || swi.Variables[0] != tt.variable || swi.Additions[0] != tt.addition ||
+			swi.StartOffsets[0] != tt.startOff || swi.EndOffsets[0] != tt.endOff {
+			t.Errorf("parseStringWithInterps(%q) = %q %d:%d %q; want %q %d:%d %q", tt.s, swi.Variables, swi.StartOffsets, swi.EndOffsets, swi.Additions,
+				tt.variable, tt.startOff, tt.endOff, tt.addition)
+		}
+	}
+}
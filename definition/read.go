@@ -1,51 +1,94 @@
 package definition
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
-	"golang.org/x/exp/slices"
+	"github.com/rothskeller/packet/message"
 )
 
 type section struct {
 	name      string
+	filename  string
+	lines     []string
 	startline int
 	endline   int
 	table     [][]string
 }
 
+// Read reads and parses the exercise definition file with the specified name.
+// It returns the first error found, if any.
 func Read(filename string) (def *Definition, err error) {
-	var contents []byte
-	var lines []string
-	var sections []section
+	var errs []error
 
-	// Read the file.
-	if contents, err = os.ReadFile(filename); err != nil {
-		return nil, err
+	if def, errs = ReadAll(filename); len(errs) != 0 {
+		return nil, errs[0]
 	}
-	// Split into lines.
-	lines = strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "").Replace(string(contents)), "\n")
-	// Split into sections.
-	if sections, err = splitSections(lines); err != nil {
-		return nil, fmt.Errorf("%s:%s", filename, err)
+	return def, nil
+}
+
+// ReadAll reads and parses the exercise definition file with the specified
+// name.  Unlike Read, it keeps going after finding an error, so that it can
+// report all of the errors in the file at once.  The returned definition is
+// usable only if there are no errors.
+func ReadAll(filename string) (def *Definition, errs []error) {
+	var sections []section
+	var sources []string
+	var err error
+
+	// Read the file and any files it includes, and split them into
+	// sections.
+	if sections, err = loadSections(filename, nil, &sources); err != nil {
+		return nil, []error{err}
 	}
 	// Parse the table in each section.
 	for i, s := range sections {
-		keyvalue := s.name == "EXERCISE" || strings.HasPrefix(s.name, "SEND ") || strings.HasPrefix(s.name, "RECEIVE ")
-		if sections[i].table, err = parseTable(lines[s.startline:s.endline], s.startline+1, keyvalue); err != nil {
-			return nil, fmt.Errorf("%s:%s", filename, err)
+		keyvalue := s.name == "EXERCISE" || s.name == "REJECTS" || strings.HasPrefix(s.name, "SEND ") || strings.HasPrefix(s.name, "RECEIVE ")
+		if sections[i].table, err = parseTable(s.lines[s.startline:s.endline], s.startline+1, keyvalue); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%s", s.filename, err))
 		}
 	}
 	// Parse the contents of each section.
 	def = &Definition{
 		Filename: filename,
+		Sources:  sources,
 		Bulletin: make(map[string]*Bulletin),
 		Send:     make(map[string]*Message),
 		Receive:  make(map[string]*Message),
 	}
+	var haveStations bool
 	for _, s := range sections {
+		if s.table == nil && s.endline > s.startline {
+			continue // table had a syntax error, reported above
+		}
+		if s.name == "STATIONS" {
+			haveStations = true
+		}
+		if strings.HasPrefix(s.name, "STATIONS ") {
+			// The station list is in a CSV file, named relative to
+			// the file containing the section.
+			haveStations = true
+			csvname := strings.TrimSpace(s.name[9:])
+			if !filepath.IsAbs(csvname) {
+				csvname = filepath.Join(filepath.Dir(s.filename), csvname)
+			}
+			if s.endline > s.startline {
+				errs = append(errs, fmt.Errorf("%s:%d: [%s] section cannot have contents", s.filename, s.startline, s.name))
+			} else if def.Stations != nil {
+				errs = append(errs, fmt.Errorf("%s:%d: already have a [STATIONS] section", s.filename, s.startline))
+			} else if err = def.readStationsCSV(csvname); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
 		switch s.name {
 		case "EXERCISE":
 			err = def.parseExercise(s.table, s.startline+1)
@@ -57,6 +100,8 @@ func Read(filename string) (def *Definition, err error) {
 			err = def.parseEvents(s.table, s.startline+1)
 		case "MATCH RECEIVE":
 			err = def.parseMatchReceive(s.table, s.startline+1)
+		case "REJECTS":
+			err = def.parseRejects(s.table, s.startline+1)
 		default:
 			if strings.HasPrefix(s.name, "BULLETIN ") {
 				err = def.parseBulletin(s.name[9:], s.table, s.startline+1)
@@ -65,29 +110,93 @@ func Read(filename string) (def *Definition, err error) {
 			} else if strings.HasPrefix(s.name, "RECEIVE ") {
 				err = def.parseReceive(s.name[8:], s.table, s.startline+1)
 			} else {
-				return nil, fmt.Errorf("%s:%d: unknown section [%s]", filename, s.startline, s.name)
+				err = fmt.Errorf("%d: unknown section [%s]", s.startline, s.name)
 			}
 		}
 		if err != nil {
-			return nil, fmt.Errorf("%s:%s", filename, err)
+			errs = append(errs, fmt.Errorf("%s:%s", s.filename, err))
+		}
+	}
+	if !haveStations {
+		// With no [STATIONS] section, the station list can come from a
+		// stations.csv file alongside the definition file.
+		if found, err := def.readStationsSidecar(); found && err != nil {
+			errs = append(errs, err)
 		}
 	}
 	if def.Exercise == nil {
-		return nil, fmt.Errorf("%s: [EXERCISE] section is required", filename)
+		errs = append(errs, fmt.Errorf("%s: [EXERCISE] section is required", filename))
 	}
 	if def.Stations == nil {
-		return nil, fmt.Errorf("%s: [STATIONS] section is required", filename)
+		errs = append(errs, fmt.Errorf("%s: [STATIONS] section is required", filename))
 	}
 	if def.Events == nil {
-		return nil, fmt.Errorf("%s: [EVENTS] section is required", filename)
+		errs = append(errs, fmt.Errorf("%s: [EVENTS] section is required", filename))
 	}
 	if def.MatchReceive == nil && len(def.Receive) != 0 {
-		return nil, fmt.Errorf("%s: [MATCH RECEIVE] section is required", filename)
+		errs = append(errs, fmt.Errorf("%s: [MATCH RECEIVE] section is required", filename))
 	}
-	if err = def.verifyCrossReferences(); err != nil {
-		return nil, fmt.Errorf("%s: %s", filename, err)
+	if def.Rejects == nil {
+		def.Rejects = defaultRejects()
 	}
-	return def, nil
+	if len(errs) != 0 {
+		// Cross-reference checks on a partially parsed definition
+		// would just report a cascade of bogus errors.
+		return def, errs
+	}
+	for _, err = range def.verifyCrossReferences() {
+		errs = append(errs, fmt.Errorf("%s: %s", filename, err))
+	}
+	return def, errs
+}
+
+// loadSections reads the named file and splits it into sections.  Any
+// [INCLUDE filename] sections are replaced by the sections of the named file,
+// which is relative to the directory of the file that includes it.  including
+// is the list of files whose [INCLUDE] led to this one, for detecting include
+// cycles.  The names of all files read are added to sources.
+func loadSections(filename string, including []string, sources *[]string) (sections []section, err error) {
+	var contents []byte
+	var lines []string
+	var split []section
+
+	// Read the file.
+	if contents, err = os.ReadFile(filename); err != nil {
+		return nil, err
+	}
+	*sources = append(*sources, filename)
+	// Split into lines.
+	lines = strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "").Replace(string(contents)), "\n")
+	// Split into sections.
+	if split, err = splitSections(lines); err != nil {
+		return nil, fmt.Errorf("%s:%s", filename, err)
+	}
+	including = append(including, filepath.Clean(filename))
+	for _, s := range split {
+		s.filename, s.lines = filename, lines
+		if !strings.HasPrefix(s.name, "INCLUDE ") {
+			sections = append(sections, s)
+			continue
+		}
+		if s.endline > s.startline {
+			return nil, fmt.Errorf("%s:%d: [INCLUDE] section cannot have contents", filename, s.startline)
+		}
+		incname := strings.TrimSpace(s.name[8:])
+		if !filepath.IsAbs(incname) {
+			incname = filepath.Join(filepath.Dir(filename), incname)
+		}
+		if slices.Contains(including, filepath.Clean(incname)) {
+			return nil, fmt.Errorf("%s:%d: include cycle: %s includes itself", filename, s.startline, incname)
+		}
+		included, err := loadSections(incname, including, sources)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s:%d: included file %s does not exist", filename, s.startline, incname)
+		} else if err != nil {
+			return nil, err
+		}
+		sections = append(sections, included...)
+	}
+	return sections, nil
 }
 
 var sectNameRE = regexp.MustCompile(`^\[([^]]+)\]\s*(?:#.*)?$`)
@@ -102,7 +211,7 @@ func splitSections(lines []string) (sections []section, err error) {
 			if match := sectNameRE.FindStringSubmatch(line); match == nil {
 				return nil, fmt.Errorf("%d: syntax error on [SECTION] line", lnum+1)
 			} else {
-				sections = append(sections, section{match[1], lnum + 1, lnum + 1, nil})
+				sections = append(sections, section{name: match[1], startline: lnum + 1, endline: lnum + 1})
 				continue
 			}
 		}
@@ -225,71 +334,116 @@ func expandInitialTabs(s string) (expanded string) {
 	return expanded
 }
 
-func (def *Definition) verifyCrossReferences() (err error) {
+func (def *Definition) verifyCrossReferences() (errs []error) {
 	var names = make(map[string]string)
 	for _, e := range def.Events {
 		if e.Type == EventReceive {
 			if !slices.ContainsFunc(def.MatchReceive, func(mr *MatchReceive) bool { return mr.Name == e.Name }) {
-				return fmt.Errorf("no entry in [MATCH RECEIVE] for message %s", e.Name)
+				errs = append(errs, fmt.Errorf("no entry in [MATCH RECEIVE] for message %s", e.Name))
 			}
 		}
 		if e.Type == EventInject {
 			if _, ok := def.Receive[e.Name]; !ok {
-				return fmt.Errorf("no [RECEIVE %s] entry for inject event", e.Name)
+				errs = append(errs, fmt.Errorf("no [RECEIVE %s] entry for inject event", e.Name))
 			}
 		}
 		if e.Type == EventSend {
 			if _, ok := def.Send[e.Name]; !ok {
-				return fmt.Errorf("no [SEND %s] entry for %s event", e.Name, eventTypeNames[e.Type])
+				errs = append(errs, fmt.Errorf("no [SEND %s] entry for %s event", e.Name, eventTypeNames[e.Type]))
+			}
+		}
+		if e.Type == EventSet {
+			if !def.variableExists(e.Variable) {
+				errs = append(errs, fmt.Errorf("[EVENT] %s %s: no such variable %q", eventTypeNames[e.Type], e.Name, e.Variable))
+			}
+			for _, vname := range e.Value.Variables {
+				if !def.variableExists(vname) {
+					errs = append(errs, fmt.Errorf("[EVENT] %s %s: value refers to nonexistent variable %s", eventTypeNames[e.Type], e.Name, vname))
+				}
+			}
+		}
+		if e.TriggerType == EventAt {
+			if def.Exercise.OpStart.IsZero() {
+				errs = append(errs, fmt.Errorf("[EVENT] %s %s: \"at\" triggers require an opstart", eventTypeNames[e.Type], e.Name))
+			} else {
+				offset, fromStart, _ := parseTriggerTime(e.TriggerName)
+				if fromStart {
+					e.TriggerTime = def.Exercise.OpStart.Add(offset)
+				} else {
+					y, m, d := def.Exercise.OpStart.Date()
+					e.TriggerTime = time.Date(y, m, d, 0, 0, 0, 0, time.Local).Add(offset)
+				}
 			}
 		}
-		if e.ConditionVar != "" && !def.variableExists(e.ConditionVar) {
-			return fmt.Errorf("[EVENT] %s %s: no such variable %q", eventTypeNames[e.Type], e.Name, e.ConditionVar)
+		if e.TriggerStation != "" && def.Station(e.TriggerStation) == nil {
+			errs = append(errs, fmt.Errorf("[EVENT] %s %s: triggered from nonexistent station %s", eventTypeNames[e.Type], e.Name, e.TriggerStation))
+		}
+		if e.TriggerQuorum != 0 && !e.TriggerPercent && e.TriggerQuorum > len(def.Stations) {
+			errs = append(errs, fmt.Errorf("[EVENT] %s %s: triggered from %d stations, but there are only %d", eventTypeNames[e.Type], e.Name, e.TriggerQuorum, len(def.Stations)))
+		}
+		if e.Repeat != nil && e.Repeat.UntilOpEnd && def.Exercise.OpEnd.IsZero() {
+			errs = append(errs, fmt.Errorf("[EVENT] %s %s: repeats until opend, but there is no opend", eventTypeNames[e.Type], e.Name))
+		}
+		if e.Condition != nil {
+			for _, vname := range e.Condition.Variables() {
+				if !def.conditionVariableExists(e, vname) {
+					errs = append(errs, fmt.Errorf("[EVENT] %s %s: no such variable %q", eventTypeNames[e.Type], e.Name, vname))
+				}
+			}
 		}
 	}
 	for i, mr := range def.MatchReceive {
 		if !slices.ContainsFunc(def.Events, func(e *Event) bool {
 			return e.Name == mr.Name
 		}) {
-			return fmt.Errorf("no receive event for message %s referenced in [MATCH RECEIVE]", mr.Name)
+			errs = append(errs, fmt.Errorf("no receive event for message %s referenced in [MATCH RECEIVE]", mr.Name))
 		}
 		for j := 0; j < i; j++ {
 			if mr.hiddenBy(def.MatchReceive[j]) {
-				return fmt.Errorf("[MATCH RECEIVE] for message %s is not reachable after %s", mr.Name, def.MatchReceive[j].Name)
+				errs = append(errs, fmt.Errorf("[MATCH RECEIVE] for message %s is not reachable after %s", mr.Name, def.MatchReceive[j].Name))
 			}
 		}
 	}
-	for name := range def.Bulletin {
+	for _, name := range slices.Sorted(maps.Keys(def.Bulletin)) {
 		if !slices.ContainsFunc(def.Events, func(e *Event) bool {
 			return e.Name == name && (e.Type == EventBulletin)
 		}) {
-			return fmt.Errorf("no bulletin event for [BULLETIN %s]", name)
+			errs = append(errs, fmt.Errorf("no bulletin event for [BULLETIN %s]", name))
 		}
 		names[name] = "BULLETIN"
 	}
-	for name, m := range def.Send {
+	for _, name := range slices.Sorted(maps.Keys(def.Send)) {
+		m := def.Send[name]
 		if names[name] != "" {
-			return fmt.Errorf("message %s cannot be both BULLETIN and SEND", name)
+			errs = append(errs, fmt.Errorf("message %s cannot be both BULLETIN and SEND", name))
 		}
 		names[name] = "SEND"
 		if !slices.ContainsFunc(def.Events, func(e *Event) bool {
 			return e.Name == name && e.Type == EventSend
 		}) {
-			return fmt.Errorf("no send event for [SEND %s]", name)
+			errs = append(errs, fmt.Errorf("no send event for [SEND %s]", name))
 		}
-		for fname, swi := range m.Fields {
-			for _, vname := range swi.Variables {
-				if !def.variableExists(vname) {
-					return fmt.Errorf("[SEND %s] value for %q refers to nonexistent variable %s", name, fname, vname)
+		for _, v := range m.AllVariants() {
+			var section = name
+			if v.Variant != "" {
+				section += "/" + v.Variant
+			}
+			for _, fname := range slices.Sorted(maps.Keys(v.Fields)) {
+				swi := v.Fields[fname]
+				for _, vname := range swi.Variables {
+					if !def.variableExists(vname) {
+						errs = append(errs, fmt.Errorf("[SEND %s] value for %q refers to nonexistent variable %s", section, fname, vname))
+					}
 				}
 			}
 		}
 	}
-	for name, m := range def.Receive {
+	for _, name := range slices.Sorted(maps.Keys(def.Receive)) {
+		var m = def.Receive[name]
 		var haveInject, haveReceive bool
 
 		if names[name] != "" {
-			return fmt.Errorf("message %s cannot be both %s and RECEIVE", name, names[name])
+			errs = append(errs, fmt.Errorf("message %s cannot be both %s and RECEIVE", name, names[name]))
 		}
 		for _, e := range def.Events {
 			if e.Name == name {
@@ -301,17 +455,48 @@ func (def *Definition) verifyCrossReferences() (err error) {
 			}
 		}
 		if !haveInject && !haveReceive {
-			return fmt.Errorf("no inject or receive event for [RECEIVE %s]", name)
+			errs = append(errs, fmt.Errorf("no inject or receive event for [RECEIVE %s]", name))
 		}
-		for fname, swi := range m.Fields {
+		for _, fname := range slices.Sorted(maps.Keys(m.Fields)) {
+			swi := m.Fields[fname]
 			for _, vname := range swi.Variables {
 				if !def.variableExists(vname) {
-					return fmt.Errorf("[RECEIVE %s] value for %q refers to nonexistent variable %s", name, fname, vname)
+					errs = append(errs, fmt.Errorf("[RECEIVE %s] value for %q refers to nonexistent variable %s", name, fname, vname))
 				}
 			}
 		}
 	}
-	return nil
+	for _, t := range []struct {
+		key string
+		swi StringWithInterps
+	}{
+		{"sendersubject", def.Rejects.SenderSubject},
+		{"senderbody", def.Rejects.SenderBody},
+		{"messagesubject", def.Rejects.MessageSubject},
+		{"messagebody", def.Rejects.MessageBody},
+	} {
+		for _, vname := range t.swi.Variables {
+			if !def.rejectVariableExists(vname) {
+				errs = append(errs, fmt.Errorf("[REJECTS] value for %q refers to nonexistent variable %s", t.key, vname))
+			}
+		}
+	}
+	return errs
+}
+
+// rejectVariableExists returns whether a variable used in a reject reply
+// exists.  Reject replies can use reject.subject and reject.from, and exercise
+// and now variables; since the rejected message may not be from a known
+// station, or match a known message, they can't use other variables.
+func (def *Definition) rejectVariableExists(vname string) bool {
+	switch group, item, _ := strings.Cut(vname, "."); group {
+	case "reject":
+		return item == "subject" || item == "from"
+	case "exercise", "now":
+		return def.variableExists(vname)
+	default:
+		return false
+	}
 }
 
 func (def *Definition) variableExists(vname string) bool {
@@ -327,8 +512,61 @@ func (def *Definition) variableExists(vname string) bool {
 		return item == "date" || item == "time" || item == "datetime"
 	default:
 		if group == "UNKNOWN" || slices.ContainsFunc(def.Events, func(e *Event) bool { return e.Name == group }) {
-			return item == "msgid" || item == "subjectline"
+			return item == "msgid" || item == "subjectline" || item == "time" || def.messageHasField(group, item)
 		}
 		return false
 	}
 }
+
+// conditionVariableExists returns whether a variable used in the condition of
+// the specified event exists.  In addition to the usual variables, conditions
+// can use trigger.XXX variables, which refer to the message exchanged in the
+// triggering event, and trigger.score, which is the score of a received
+// triggering message.
+func (def *Definition) conditionVariableExists(e *Event, vname string) bool {
+	group, item, _ := strings.Cut(vname, ".")
+	if group != "trigger" {
+		return def.variableExists(vname)
+	}
+	if e.TriggerOverdue {
+		return false // the triggering event hasn't happened
+	}
+	// With multiple triggers, it's enough for the variable to exist for any
+	// of them.
+	return slices.ContainsFunc(e.TriggerList(), func(t Trigger) bool {
+		switch t.Type {
+		case EventReceive:
+			if item == "score" {
+				return true
+			}
+		case EventSend, EventBulletin:
+			break
+		default:
+			return false // no message exchanged in triggering event
+		}
+		return item == "msgid" || item == "subjectline" || item == "time" || def.messageHasField(t.Name, item)
+	})
+}
+
+// messageHasField returns whether the named message has a field with the
+// specified label.  A received message need not have a [RECEIVE] section; if it
+// doesn't, its type is taken from its [MATCH RECEIVE] entry.
+func (def *Definition) messageHasField(name, label string) bool {
+	var mtype, version string
+
+	if m := def.Send[name]; m != nil {
+		mtype, version = m.Type, m.Version
+	} else if m := def.Receive[name]; m != nil {
+		mtype, version = m.Type, m.Version
+	} else if def.Bulletin[name] != nil {
+		mtype = "plain"
+	} else if idx := slices.IndexFunc(def.MatchReceive, func(mr *MatchReceive) bool { return mr.Name == name }); idx >= 0 && def.MatchReceive[idx].Type != "" {
+		mtype = def.MatchReceive[idx].Type
+	} else {
+		return false
+	}
+	if blank := message.Create(mtype, version); blank != nil {
+		return slices.ContainsFunc(blank.Base().Fields, func(f *message.Field) bool { return f.Label == label })
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package definition
+
+import (
+	"testing"
+
+	"github.com/rothskeller/packet/xscmsg"
+)
+
+func TestMessageHasField(t *testing.T) {
+	xscmsg.Register()
+	var def = Definition{
+		Send: map[string]*Message{"AskStatus": {Type: "plain"}},
+		MatchReceive: []*MatchReceive{
+			{Name: "CheckIn", Type: "plain", Subject: "Check-In"},
+			{Name: "Anything", Subject: "Anything"},
+		},
+	}
+	tests := []struct {
+		name, label string
+		want        bool
+	}{
+		{"AskStatus", "Subject", true},
+		{"AskStatus", "Shelter Name", false},
+		// A received message with no [RECEIVE] section gets its type
+		// from its [MATCH RECEIVE] entry.
+		{"CheckIn", "Subject", true},
+		{"CheckIn", "Shelter Name", false},
+		// Without a type there, its fields aren't known.
+		{"Anything", "Subject", false},
+		{"Unknown", "Subject", false},
+	}
+	for _, tt := range tests {
+		if got := def.messageHasField(tt.name, tt.label); got != tt.want {
+			t.Errorf("messageHasField(%q, %q) = %v, want %v", tt.name, tt.label, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package engine
+
+import "time"
+
+// A clock is a virtual clock that runs speed times faster than real time,
+// starting at vstart when the real time is rstart.  A nil clock is the real
+// time clock.
+type clock struct {
+	vstart time.Time
+	rstart time.Time
+	speed  float64
+}
+
+// newClock creates a new virtual clock, reading start now, running at the
+// specified multiple of real time.
+func newClock(start time.Time, speed float64) *clock {
+	return &clock{vstart: start, rstart: time.Now(), speed: speed}
+}
+
+// Now returns the current time on the clock.
+func (c *clock) Now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.vstart.Add(time.Duration(float64(time.Since(c.rstart)) * c.speed))
+}
+
+// Until returns the real time duration until the clock reads t.
+func (c *clock) Until(t time.Time) time.Duration {
+	if c == nil {
+		return time.Until(t)
+	}
+	return time.Duration(float64(t.Sub(c.Now())) / c.speed)
+}
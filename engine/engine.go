@@ -23,8 +23,11 @@ type Engine struct {
 	listener net.Listener
 	conn     BBSConnector
 	noinject bool
+	speed    float64
+	clock    *clock
 	tickch   <-chan time.Time
 	mtch     chan server.ManualTrigger
+	reloadch <-chan reload
 }
 type BBSConnector func(*definition.Exercise) (BBSConnection, error)
 
@@ -51,6 +54,12 @@ func (e *Engine) SetNoInject() {
 	e.noinject = true
 }
 
+// SetSpeed sets the speed of the exercise clock, as a multiple of real time.
+// If called at all, it must be called before StartTicker.
+func (e *Engine) SetSpeed(speed float64) {
+	e.speed = speed
+}
+
 // SetBBSConnector sets the BBS connector to use for connecting to the BBS.
 // This is typically called before Run.
 func (e *Engine) SetBBSConnector(conn BBSConnector) {
@@ -83,6 +92,8 @@ func (e *Engine) Run() {
 			e.ManualTrigger(mt)
 		case tick := <-e.tickch:
 			e.ClockTick(tick)
+		case rl := <-e.reloadch:
+			e.reloadDefinition(rl)
 		}
 	}
 }
@@ -127,7 +138,7 @@ func (e *Engine) startNewStations() {
 		// If any bulletins have been sent, "send" the bulletins to that
 		// station and trigger any events based on that.
 		for _, sb := range e.st.SentBulletins() {
-			ev = e.st.SendMessage(sb.Type(), stn.CallSign, sb.Name(), sb.LMI(), "", sb.Trigger())
+			ev = e.st.SendMessage(sb.Type(), stn.CallSign, sb.Name(), sb.LMI(), "", sb.Triggers()...)
 			e.runTriggers(ev)
 		}
 	}
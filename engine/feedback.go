@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rothskeller/packet-ex/state"
+	"github.com/rothskeller/packet/envelope"
+	"github.com/rothskeller/packet/incident"
+	"github.com/rothskeller/packet/message"
+	"github.com/rothskeller/packet/xscmsg/plaintext"
+)
+
+// sendFeedback sends a message back to the station that sent a message, listing
+// the problems found in it (and its score, if so configured), if the exercise
+// definition calls for feedback on it.  ev is the receive event for the message,
+// and subject is its subject line.  No feedback is sent if conn is nil.
+func (e *Engine) sendFeedback(conn BBSConnection, ev *state.Event, subject string, problems []string, score int) {
+	var feedback = e.def.Feedback(ev.Name())
+	if feedback == "none" || conn == nil {
+		return
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s received a message from you with\n  Subject: %s\n", e.def.Exercise.MyName, subject)
+	if len(problems) == 0 {
+		body.WriteString("No problems were found in it.\n")
+	} else {
+		body.WriteString("The following problems were found in it:\n")
+		for _, problem := range problems {
+			fmt.Fprintf(&body, "  - %s\n", problem)
+		}
+	}
+	if feedback == "score" {
+		fmt.Fprintf(&body, "Its score is %d%%.\n", score)
+	}
+	lmi := incident.UniqueMessageID(e.def.Exercise.StartMsgID)
+	env := &envelope.Envelope{From: e.myFrom(), To: e.st.AddressForStation(ev.Station())}
+	msg := message.Create("plain", "").(*plaintext.PlainText)
+	msg.Subject = "Feedback: " + subject
+	msg.Body = body.String()
+	if mn := msg.Base().FOriginMsgID; mn != nil {
+		*mn = lmi
+	}
+	if h := msg.Base().FHandling; h != nil {
+		*h = "ROUTINE"
+	}
+	if err := incident.SaveMessage(lmi, "", env, msg, true, false); err != nil {
+		e.st.LogError(fmt.Errorf("saving feedback message: %w", err))
+		return
+	}
+	if err := e.sendMessage(conn, nil, lmi, env, msg); err != nil {
+		return // already logged; feedback isn't retried
+	}
+	e.st.SendFeedback(ev, lmi, env.SubjectLine)
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet/message"
+)
+
+// fuzzyThreshold is the similarity (between 0 and 1) that a received message
+// must have with a [MATCH RECEIVE] entry to be taken as a near match for it.
+const fuzzyThreshold = 0.75
+
+// subjectWeight is the part of that similarity that comes from the subject;
+// the rest comes from the form type.  It is high enough that a message with
+// the right type needs a fairly similar subject, and a message with the wrong
+// type needs an almost exact one.
+const subjectWeight = 0.85
+
+// fuzzyMatchMessage returns the name of the [MATCH RECEIVE] entry that the
+// supplied message is most similar to, if it is similar enough to be a likely
+// match, or an empty string if not.  It is used for messages that don't match
+// any entry exactly.
+func (e *Engine) fuzzyMatchMessage(subjectline string, msg message.Message) (name string) {
+	if e.def.Exercise.FuzzyMatch == "off" {
+		return ""
+	}
+	var subject, formtag = matchFields(subjectline, msg)
+	return fuzzyMatch(e.def.MatchReceive, subject, formtag)
+}
+
+// fuzzyMatch returns the name of the entry in entries that a message with the
+// specified subject and form tag is most similar to, if it is similar enough,
+// or an empty string if not.  The similarity of an entry is mostly that of its
+// subject, with the rest coming from its type (if it has one), so that a
+// message with a nearly right subject on a different form can still be offered
+// as a near match, but a matching type alone is never enough.  If several
+// entries are equally similar, the first one wins.
+func fuzzyMatch(entries []*definition.MatchReceive, subject, formtag string) (name string) {
+	var best float64
+
+	for _, md := range entries {
+		var score, typeScore = 1.0, 1.0
+
+		if md.Subject == "" && md.SubjectRE == nil {
+			// There's no subject to compare.  If the type matches,
+			// this would have been an exact match.
+			continue
+		}
+		if md.Subject != "" {
+			score = min(score, similarity(md.Subject, subject))
+		}
+		if md.SubjectRE != nil {
+			// We can't measure the distance to a regular
+			// expression, but we can compare the words in it.
+			score = min(score, wordOverlap(literalText(md.SubjectREText), subject))
+		}
+		if md.Type != "" && md.Type != formtag {
+			typeScore = similarity(md.Type, formtag)
+		}
+		score = subjectWeight*score + (1-subjectWeight)*typeScore
+		if score > best {
+			best, name = score, md.Name
+		}
+	}
+	if best < fuzzyThreshold {
+		return ""
+	}
+	return name
+}
+
+// literalText returns the literal text of the regular expression re, with a
+// space in place of each part of it that isn't literal (such as a character
+// class, a repetition, or an alternation).  Its words are the words that every
+// subject matching the regular expression has.
+func literalText(re string) string {
+	var sb strings.Builder
+
+	parsed, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	var walk func(*syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpLiteral:
+			sb.WriteString(string(re.Rune))
+		case syntax.OpConcat, syntax.OpCapture:
+			for _, sub := range re.Sub {
+				walk(sub)
+			}
+		default:
+			sb.WriteByte(' ')
+		}
+	}
+	walk(parsed)
+	return sb.String()
+}
+
+// similarity returns how similar two strings are, between 0 (nothing in
+// common) and 1 (the same, ignoring case).  It is the better of their edit
+// distance similarity and their word overlap, so that both typos and reordered
+// or missing words are tolerated.
+func similarity(a, b string) float64 {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return 1
+	}
+	var ra, rb = []rune(a), []rune(b)
+	var edit = 1 - float64(editDistance(ra, rb))/float64(max(len(ra), len(rb)))
+	return max(edit, wordOverlap(a, b))
+}
+
+// editDistance returns the Levenshtein edit distance between two strings.
+func editDistance(a, b []rune) int {
+	var prev, cur = make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range a {
+		cur[0] = i + 1
+		for j := range b {
+			cost := 1
+			if a[i] == b[j] {
+				cost = 0
+			}
+			cur[j+1] = min(prev[j+1]+1, cur[j]+1, prev[j]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+var wordRE = regexp.MustCompile(`[[:alnum:]]+`)
+
+// wordOverlap returns the fraction of the distinct words in two strings,
+// ignoring case, that are in both of them.
+func wordOverlap(a, b string) float64 {
+	var words = make(map[string]int)
+	for _, w := range wordRE.FindAllString(strings.ToLower(a), -1) {
+		words[w] |= 1
+	}
+	for _, w := range wordRE.FindAllString(strings.ToLower(b), -1) {
+		words[w] |= 2
+	}
+	if len(words) == 0 {
+		return 0
+	}
+	var both int
+	for _, in := range words {
+		if in == 3 {
+			both++
+		}
+	}
+	return float64(both) / float64(len(words))
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/rothskeller/packet-ex/definition"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"check-in", "check-in", 0},
+		{"check-in", "chek-in", 1},
+		{"check-in", "checkin", 1},
+		{"kitten", "sitting", 3},
+		{"shelter", "shleter", 2},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestWordOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 0},
+		{"Shelter Status", "shelter status", 1},
+		{"Shelter Status", "Status Shelter", 1},
+		{"Shelter Status", "Shelter Status Report", 2.0 / 3},
+		{"Shelter Status", "Shelter Request", 1.0 / 3},
+		{"Check-In", "check in", 1},
+		{"Check-In", "Status", 0},
+		{"Status, Status", "status", 1},
+	}
+	for _, tt := range tests {
+		if got := wordOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("wordOverlap(%q, %q) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLiteralText(t *testing.T) {
+	tests := []struct {
+		re   string
+		want []string // words
+	}{
+		{`Shelter Status`, []string{"Shelter", "Status"}},
+		{`Shelter Status \d+`, []string{"Shelter", "Status"}},
+		{`Check.In`, []string{"Check", "In"}},
+		{`(Shelter) Status( Report)?`, []string{"Shelter", "Status"}},
+		{`Resource Request (A|B)`, []string{"Resource", "Request"}},
+		{`\w+`, nil},
+	}
+	for _, tt := range tests {
+		if got := wordRE.FindAllString(literalText(tt.re), -1); !slices.Equal(got, tt.want) {
+			t.Errorf("literalText(%q) words = %q, want %q", tt.re, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	var entries = []*definition.MatchReceive{
+		{Name: "CheckIn", Type: "plain", Subject: "Check-In"},
+		{Name: "SheltStat", Type: "SheltStat", Subject: "Shelter Status"},
+		{Name: "SheltReq", Type: "plain", Subject: "Shelter Request"},
+		{Name: "ResReq", Type: "EOC213RR", SubjectRE: regexp.MustCompile(`^(?i:Resource Request \d+)$`), SubjectREText: `Resource Request \d+`},
+		{Name: "Status1", Type: "plain", Subject: "Status Report"},
+		{Name: "Status2", Type: "plain", Subject: "Status Report"},
+		{Name: "Any213", Type: "ICS213"},
+	}
+	tests := []struct {
+		subject, formtag string
+		want             string
+	}{
+		// A typo in the subject.
+		{"Chek-In", "plain", "CheckIn"},
+		{"Checkin", "plain", "CheckIn"},
+		// The right subject with the wrong type is a near match, but a
+		// typo as well is too much.
+		{"Check-In", "ICS213", "CheckIn"},
+		{"Shelter Status", "plain", "SheltStat"},
+		{"Chek-In", "ICS213", ""},
+		// A matching type alone isn't enough.
+		{"Shelter Report", "SheltStat", ""},
+		// Words of a regular expression, but not its syntax, are compared.
+		{"Resource Request", "EOC213RR", "ResReq"},
+		{"Resource Request for water", "EOC213RR", ""},
+		{"d i", "EOC213RR", ""},
+		// Ties go to the first entry.
+		{"Status Reprot", "plain", "Status1"},
+		// An entry with only a type isn't a near match for anything.
+		{"Anything", "ICS213", ""},
+	}
+	for _, tt := range tests {
+		if got := fuzzyMatch(entries, tt.subject, tt.formtag); got != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) = %q, want %q", tt.subject, tt.formtag, got, tt.want)
+		}
+	}
+}
@@ -20,7 +20,7 @@ import (
 func (e *Engine) generateSendMessage(ev *state.Event) (lmi string, env *envelope.Envelope, msg message.Message) {
 	lmi = incident.UniqueMessageID(e.def.Exercise.StartMsgID)
 	env = &envelope.Envelope{From: e.myFrom(), To: e.st.AddressForStation(ev.Station())}
-	msg = e.generateMessage(e.def.Send[ev.Name()], ev.Station())
+	msg = e.generateMessage(e.chooseVariant(e.def.Send[ev.Name()], ev.ID()), ev.Station())
 	e.setMessageDefaults(msg, ev.Station(), false)
 	if mn := msg.Base().FOriginMsgID; mn != nil {
 		*mn = lmi
@@ -194,11 +194,17 @@ func (e *Engine) myFrom() string {
 // generateValue builds a message field value from a template that may have
 // interpolated variables.
 func (e *Engine) generateValue(tmpl definition.StringWithInterps, station string) (val string) {
+	return e.interpolate(tmpl, func(vname string) (string, bool) { return e.Variable(vname, station) })
+}
+
+// interpolate builds a string from a template that may have interpolated
+// variables, whose values are given by the supplied lookup function.
+func (e *Engine) interpolate(tmpl definition.StringWithInterps, lookup func(string) (string, bool)) (val string) {
 	var sb strings.Builder
 
 	for i := range len(tmpl.Variables) {
 		sb.WriteString(tmpl.Literals[i])
-		if vval, ok := e.Variable(tmpl.Variables[i], station); !ok {
+		if vval, ok := lookup(tmpl.Variables[i]); !ok {
 			e.st.LogError(fmt.Errorf("no such variable %q", tmpl.Variables[i]))
 		} else {
 			sidx, eidx := tmpl.StartOffsets[i], tmpl.EndOffsets[i]
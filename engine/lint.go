@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet-ex/state"
+)
+
+// LintTemplates generates every inject message in the exercise definition, for
+// every station, and returns a warning for each one that fails PackItForms
+// validation.  Variables referring to other messages have no value at this
+// point; warnings for templates that use them say so.
+func LintTemplates(def *definition.Definition) (warnings []string) {
+	var e = &Engine{def: def, st: state.New(false)}
+
+	for _, ev := range def.Events {
+		if ev.Type != definition.EventInject {
+			continue
+		}
+		var tmpl = def.Receive[ev.Name]
+		var stations = make(map[string][]string) // problem => stations
+		for _, stn := range def.Stations {
+			msg := e.generateMessage(tmpl, stn.CallSign)
+			if msg == nil || msg.Base().FToICSPosition == nil {
+				continue // not a form, so PIFOValid doesn't apply
+			}
+			e.setMessageDefaults(msg, stn.CallSign, true)
+			for _, problem := range msg.Base().PIFOValid() {
+				stations[problem] = append(stations[problem], stn.CallSign)
+			}
+		}
+		var note string
+		if usesMessageVariables(tmpl) {
+			note = " (message variables were blank)"
+		}
+		for _, problem := range slices.Sorted(maps.Keys(stations)) {
+			var who = strings.Join(stations[problem], ", ")
+			if len(stations[problem]) == len(def.Stations) {
+				who = "all stations"
+			}
+			warnings = append(warnings, fmt.Sprintf("[RECEIVE %s] inject for %s: %s%s", ev.Name, who, problem, note))
+		}
+	}
+	return warnings
+}
+
+// usesMessageVariables returns whether a message template refers to variables
+// from other messages.
+func usesMessageVariables(tmpl *definition.Message) bool {
+	for _, swi := range tmpl.Fields {
+		for _, vname := range swi.Variables {
+			switch group, _, _ := strings.Cut(vname, "."); group {
+			case "exercise", "station", "now":
+				break
+			default:
+				return true
+			}
+		}
+	}
+	return false
+}
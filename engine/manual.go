@@ -17,8 +17,19 @@ func (e *Engine) ManualTrigger(mt server.ManualTrigger) {
 			// (Re-)schedule the event for next tick.
 			e.st.ScheduleEvent(mt.Type, mt.Station, mt.Name, e.st.Now(), 0)
 		}
+	case definition.EventSet:
+		if edef := e.def.Event(mt.Type, mt.Name); edef != nil && mt.Station != "" {
+			// Set the variable now and run associated triggers.
+			if ev := e.doSet(edef, mt.Station, 0); ev != nil {
+				e.runTriggers(ev)
+			}
+		}
 	case definition.EventAlert, definition.EventDeliver, definition.EventReceive:
-		if mt.Station != "" {
+		if mt.Type == definition.EventReceive && mt.LMI != "" {
+			// Process the rejected message as the named message
+			// from the station.
+			e.reassignMessage(mt.LMI, mt.Station, mt.Name)
+		} else if mt.Station != "" {
 			// Mark the event as having occurred (creating it if
 			// need be) and run associated triggers.
 			ev := e.st.RecordEvent(mt.Type, mt.Station, mt.Name)
@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+
+	"github.com/rothskeller/packet-ex/definition"
+)
+
+// random returns a pseudo-random number in the range [0, n).  It is determined
+// by the random seed of the exercise and the supplied key values, so that it
+// comes out the same when the engine is restarted or the exercise is replayed.
+func (e *Engine) random(n int, key ...any) int {
+	var h = fnv.New64a()
+	for _, k := range key {
+		fmt.Fprintf(h, "%v|", k)
+	}
+	return rand.New(rand.NewPCG(uint64(e.st.Seed()), h.Sum64())).IntN(n)
+}
+
+// delay returns the delay of the event edef for the specified station, when
+// triggered by the specified trigger events.  If the event has a random delay,
+// it is chosen to the second.
+func (e *Engine) delay(edef *definition.Event, station string, triggers []int) time.Duration {
+	if edef.DelayMax == 0 {
+		return edef.Delay
+	}
+	spread := int((edef.DelayMax-edef.Delay)/time.Second) + 1
+	return edef.Delay + time.Duration(e.random(spread, "delay", edef.Type, station, edef.Name, triggers))*time.Second
+}
+
+// chooseVariant returns the variant of the message tmpl to be sent for the
+// event with the specified ID.
+func (e *Engine) chooseVariant(tmpl *definition.Message, eid int) *definition.Message {
+	if len(tmpl.Variants) == 0 {
+		return tmpl
+	}
+	variants := tmpl.AllVariants()
+	return variants[e.random(len(variants), "variant", eid)]
+}
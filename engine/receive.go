@@ -3,9 +3,12 @@ package engine
 import (
 	"errors"
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 
 	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet-ex/state"
 	"github.com/rothskeller/packet/envelope"
 	"github.com/rothskeller/packet/incident"
 	"github.com/rothskeller/packet/message"
@@ -73,6 +76,10 @@ func (e *Engine) sendDeliveryReceipt(conn BBSConnection, lmi string, env *envelo
 		Address: strings.ToLower(e.def.Exercise.MyCall + "@" + e.def.Exercise.BBSName + ".scc-ares-races.org"),
 	}).String()
 	env.Date = e.st.Now()
+	// The receipt says when the message was delivered by the exercise
+	// clock, which isn't the wall clock when running at speed or in
+	// simulation.
+	dr.DeliveredTime = env.Date.Format("01/02/2006 15:04")
 	dr.SetOperator(e.def.Exercise.OpCall, e.def.Exercise.OpName, false)
 	body := dr.EncodeBody()
 	var to []string
@@ -96,34 +103,68 @@ func (e *Engine) sendDeliveryReceipt(conn BBSConnection, lmi string, env *envelo
 }
 
 func (e *Engine) processReceivedMessage(conn BBSConnection, raw, lmi string, env *envelope.Envelope, msg message.Message) {
-	// Determine the return address.
-	var from = env.From
-	if addrs, err := envelope.ParseAddressList(from); err == nil && len(addrs) != 0 {
-		from = addrs[0].Address
-	}
-	if strings.IndexByte(from, ' ') >= 0 {
-		from = "" // can't record that
-	}
+	var from = returnAddress(env)
 	// Which station is it from?
 	var station = e.stationFromAddress(env.From)
 	if station.CallSign == "UNKNOWN" {
-		e.st.RecordReject(station.CallSign, "-", lmi, from, env.SubjectLine)
+		e.st.RecordReject(station.CallSign, "-", lmi, from, env.SubjectLine, "")
 		e.rejectUnknownSender(conn, env)
 		return
 	}
-	// Which message template does it match?
+	// Which message template does it match?  If none exactly, is it close
+	// enough to one to take it as that one, or at least to suggest it?
 	var msgname = e.matchMessage(env.SubjectLine, msg)
+	var warning string
 	if msgname == "UNKNOWN" {
-		e.st.RecordReject(station.CallSign, msgname, lmi, from, env.SubjectLine)
-		e.rejectUnknownMessage(conn, env)
+		var suggest = e.fuzzyMatchMessage(env.SubjectLine, msg)
+		if suggest == "" || e.def.Exercise.FuzzyMatch != "auto" {
+			e.st.RecordReject(station.CallSign, msgname, lmi, from, env.SubjectLine, suggest)
+			e.rejectUnknownMessage(conn, env)
+			return
+		}
+		msgname = suggest
+		warning = fmt.Sprintf("    WARNING: message only approximately matches %s", msgname)
+	}
+	e.acceptMessage(conn, station, msgname, raw, lmi, from, env, msg, warning)
+}
+
+// acceptMessage records, analyzes, and scores a received message from station,
+// which has been matched with msgname, and runs any triggers based on it.
+// warning, if not empty, is a note to be logged with the reception.
+func (e *Engine) acceptMessage(conn BBSConnection, station *definition.Station, msgname, raw, lmi, from string, env *envelope.Envelope, msg message.Message, warning string) {
+	var rmi string
+	if of := msg.Base().FOriginMsgID; of != nil {
+		rmi = *of
+	}
+	// If it's a resend of a message we already received, record it as
+	// another copy of that one, and rescore it.  It doesn't trigger
+	// anything again.
+	if ev := e.st.FindResentMessage(station.CallSign, msgname, rmi, env.SubjectLine); ev != nil {
+		ev = e.st.ResendMessage(ev, lmi, rmi, from, env.SubjectLine)
+		if warning != "" {
+			e.st.Execute(warning)
+		}
+		var problems, score = e.analyze(station, msgname, raw, lmi, env, msg)
+		e.st.ScoreMessage(ev, problems, score)
+		// The event's notes are those of the latest copy, so if the
+		// message is still unexpected, say so again.
+		if ev.Expected().IsZero() {
+			e.st.Execute("    ERROR: unexpected/early message")
+		}
+		e.sendFeedback(conn, ev, env.SubjectLine, problems, score)
 		return
 	}
 	// Record the reception of the message.
-	var ev = e.st.ReceiveMessage(station.CallSign, msgname, lmi, from, env.SubjectLine)
+	var ev = e.st.ReceiveMessage(station.CallSign, msgname, lmi, rmi, from, env.SubjectLine)
+	if warning != "" {
+		e.st.Execute(warning)
+	}
 	// Analyze the message.
 	var problems, score = e.analyze(station, msgname, raw, lmi, env, msg)
-	// Record the analysis of the message.
+	// Record the analysis of the message, and tell the sender about it if
+	// called for.
 	e.st.ScoreMessage(ev, problems, score)
+	e.sendFeedback(conn, ev, env.SubjectLine, problems, score)
 	// Trigger any events based on this message.
 	if !ev.Expected().IsZero() {
 		e.runTriggers(ev)
@@ -132,6 +173,92 @@ func (e *Engine) processReceivedMessage(conn BBSConnection, raw, lmi string, env
 	}
 }
 
+// reassignMessage processes the rejected message with the specified LMI as if
+// it had been received as the named message from the specified station.  This
+// is done at the request of the exercise manager.  If station is empty, it is
+// the station that sent the message.  If msgname is empty, the message is
+// matched against the [MATCH RECEIVE] entries as usual.
+func (e *Engine) reassignMessage(lmi, station, msgname string) {
+	var (
+		reject *state.Event
+		stn    *definition.Station
+		raw    []byte
+		env    *envelope.Envelope
+		msg    message.Message
+		from   string
+		conn   BBSConnection
+		err    error
+	)
+	if reject = e.st.FindReject(lmi); reject == nil {
+		e.st.LogError(fmt.Errorf("reassign %s: no such rejected message", lmi))
+		return
+	}
+	if tostn, _ := reject.ReassignedTo(); tostn != "" {
+		e.st.LogError(fmt.Errorf("reassign %s: already reassigned", lmi))
+		return
+	}
+	if station == "" {
+		station = reject.Station()
+	}
+	if stn = e.def.Station(station); stn == nil {
+		e.st.LogError(fmt.Errorf("reassign %s: no such station %q", lmi, station))
+		return
+	}
+	if msgname != "" && !slices.ContainsFunc(e.def.MatchReceive, func(mr *definition.MatchReceive) bool { return mr.Name == msgname }) {
+		e.st.LogError(fmt.Errorf("reassign %s: no such received message %q", lmi, msgname))
+		return
+	}
+	// Read the saved message.
+	if raw, err = os.ReadFile(lmi + ".txt"); err == nil {
+		env, msg, err = incident.ReadMessage(lmi)
+	}
+	if err != nil {
+		e.st.LogError(fmt.Errorf("reassign %s: %w", lmi, err))
+		return
+	}
+	if msgname == "" {
+		if msgname = e.matchMessage(env.SubjectLine, msg); msgname == "UNKNOWN" {
+			e.st.LogError(fmt.Errorf("reassign %s: message does not match any received message", lmi))
+			return
+		}
+	}
+	// If the message came from some other address than the station's
+	// (e.g., the operator's personal call sign), don't record that as the
+	// station's address, or replies would go there.
+	if e.stationFromAddress(env.From) == stn {
+		from = returnAddress(env)
+	}
+	e.st.ReassignReject(reject, station, msgname)
+	// Sending feedback on the message needs a BBS connection.
+	if e.conn != nil && e.def.Feedback(msgname) != "none" {
+		if conn, err = e.conn(e.def.Exercise); err != nil {
+			e.st.LogError(err)
+			conn = nil
+		} else {
+			defer func() {
+				if err = conn.Close(); err != nil {
+					e.st.LogError(err)
+				}
+			}()
+		}
+	}
+	e.acceptMessage(conn, stn, msgname, string(raw), lmi, from, env, msg, "")
+}
+
+// returnAddress returns the address to which replies to the message with the
+// supplied envelope should be sent, or an empty string if it can't be
+// recorded.
+func returnAddress(env *envelope.Envelope) (from string) {
+	from = env.From
+	if addrs, err := envelope.ParseAddressList(from); err == nil && len(addrs) != 0 {
+		from = addrs[0].Address
+	}
+	if strings.IndexByte(from, ' ') >= 0 {
+		from = "" // can't record that
+	}
+	return from
+}
+
 // stationFromAddress returns the defined station corresponding to the call sign
 // extracted from the supplied message address.  If there is no match, it will
 // return an artificial "station" with the call sign "UNKNOWN".
@@ -151,13 +278,7 @@ func (e *Engine) stationFromAddress(addrs string) *definition.Station {
 // matchMessage returns the message name of the supplied message, or "UNKNOWN"
 // if the supplied message doesn't match any defined message.
 func (e *Engine) matchMessage(subjectline string, msg message.Message) (name string) {
-	var (
-		subject = subjectline
-		formtag = msg.Base().Type.Tag
-	)
-	if sf := msg.Base().FSubject; sf != nil {
-		subject = *sf
-	}
+	var subject, formtag = matchFields(subjectline, msg)
 	for _, md := range e.def.MatchReceive {
 		if md.Type != "" && md.Type != formtag {
 			continue
@@ -173,31 +294,45 @@ func (e *Engine) matchMessage(subjectline string, msg message.Message) (name str
 	return "UNKNOWN"
 }
 
+// matchFields returns the subject and form tag of the supplied message, for
+// matching against the [MATCH RECEIVE] entries.  The subject is taken from the
+// form's subject field if it has one, or else the subject line.
+func matchFields(subjectline string, msg message.Message) (subject, formtag string) {
+	subject, formtag = subjectline, msg.Base().Type.Tag
+	if sf := msg.Base().FSubject; sf != nil {
+		subject = *sf
+	}
+	return subject, formtag
+}
+
 // rejectUnknownSender sends a message back to the sender saying that we don't
 // know who they are.
 func (e *Engine) rejectUnknownSender(conn BBSConnection, reject *envelope.Envelope) {
-	var body = fmt.Sprintf(`%s received a message from you with
-  Subject: %s
-The mailbox you sent this message from does not correspond to any station
-participating in the current exercise.  Please make sure you are sending from
-the correct mailbox (e.g., your assigned tactical callsign, not your personal
-FCC callsign).  If you cannot find the problem, ask for help from the exercise
-manager.`, e.def.Exercise.MyName, reject.SubjectLine)
-	if err := conn.Send("REJECT: "+reject.SubjectLine, body, reject.From); err != nil {
-		e.st.LogError(fmt.Errorf("sending reject message: %w", err))
-	}
+	e.sendReject(conn, reject, e.def.Rejects.SenderSubject, e.def.Rejects.SenderBody)
 }
 
 // rejectUnknownMessage sends a message back to the sender saying that we
 // couldn't recognize their message.
 func (e *Engine) rejectUnknownMessage(conn BBSConnection, reject *envelope.Envelope) {
-	var body = fmt.Sprintf(`%s received a message from you with
-  Subject: %s
-This subject line does not match any of the messages the exercise automation
-was expecting to receive.  Please check the subject line and try again.  If you
-cannot find the problem, ask for help from the exercise manager.`,
-		e.def.Exercise.MyName, reject.SubjectLine)
-	if err := conn.Send("REJECT: "+reject.SubjectLine, body, reject.From); err != nil {
+	e.sendReject(conn, reject, e.def.Rejects.MessageSubject, e.def.Rejects.MessageBody)
+}
+
+// sendReject sends a reply to a rejected message, built from the supplied
+// templates, unless the exercise definition says rejects are silent.
+func (e *Engine) sendReject(conn BBSConnection, reject *envelope.Envelope, subject, body definition.StringWithInterps) {
+	if e.def.Rejects.Silent {
+		return
+	}
+	var lookup = func(vname string) (string, bool) {
+		switch vname {
+		case "reject.subject":
+			return reject.SubjectLine, true
+		case "reject.from":
+			return reject.From, true
+		}
+		return e.Variable(vname, "")
+	}
+	if err := conn.Send(e.interpolate(subject, lookup), e.interpolate(body, lookup), reject.From); err != nil {
 		e.st.LogError(fmt.Errorf("sending reject message: %w", err))
 	}
 }
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"os"
+	"time"
+
+	"github.com/rothskeller/packet-ex/definition"
+)
+
+// reloadInterval is how often the exercise definition files are checked for
+// changes.
+const reloadInterval = 5 * time.Second
+
+// A reload is the result of rereading the exercise definition after it
+// changed: either the new definition or the error that prevented reading it.
+type reload struct {
+	def *definition.Definition
+	err error
+}
+
+// WatchDefinition starts watching the exercise definition file (and any files
+// it includes) for changes.  Whenever they change, the definition is reread
+// and, if it is valid, replaces the one the engine is using.  If called at
+// all, it must be called before Run.
+func (e *Engine) WatchDefinition(filename string) {
+	var ch = make(chan reload)
+
+	e.reloadch = ch
+	go func() {
+		var mtimes = sourceMTimes(e.def.Sources)
+		for range time.Tick(reloadInterval) {
+			var (
+				rl     reload
+				latest = sourceMTimes(mtimes.sources)
+			)
+			if latest.equal(mtimes) {
+				continue
+			}
+			// Something changed.  Reread the definition.  Note that if
+			// the read succeeds, the set of source files may have
+			// changed too.
+			if rl.def, rl.err = definition.Read(filename); rl.err == nil {
+				latest = sourceMTimes(rl.def.Sources)
+			}
+			mtimes = latest
+			ch <- rl
+		}
+	}()
+}
+
+// reloadDefinition handles the result of rereading the exercise definition.
+func (e *Engine) reloadDefinition(rl reload) {
+	if rl.err != nil {
+		// Keep running with the old definition, but make sure the
+		// problem is noticed.
+		e.st.LogError(rl.err)
+		e.monitor.SetDefinitionError(rl.err)
+		return
+	}
+	e.def = rl.def
+	e.st.LogDefinitionReloaded()
+	e.monitor.SetDefinition(rl.def)
+	e.startNewStations()
+}
+
+// sourceTimes records the modification times of a set of source files.  A
+// file that can't be read has a zero time.
+type sourceTimes struct {
+	sources []string
+	mtimes  []time.Time
+}
+
+func sourceMTimes(sources []string) (st sourceTimes) {
+	st.sources = sources
+	for _, fname := range sources {
+		var mtime time.Time
+
+		if fi, err := os.Stat(fname); err == nil {
+			mtime = fi.ModTime()
+		}
+		st.mtimes = append(st.mtimes, mtime)
+	}
+	return st
+}
+
+func (st sourceTimes) equal(other sourceTimes) bool {
+	if len(st.mtimes) != len(other.mtimes) {
+		return false
+	}
+	for i := range st.mtimes {
+		if !st.mtimes[i].Equal(other.mtimes[i]) {
+			return false
+		}
+	}
+	return true
+}
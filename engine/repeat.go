@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet-ex/state"
+)
+
+// scheduleRepeats creates the next instance of each repeating event whose
+// latest instance is due.  Each new instance is scheduled or expected one
+// repeat interval after the previous one, and records the previous one as its
+// trigger.
+func (e *Engine) scheduleRepeats() {
+	for _, ev := range e.st.AllEvents() {
+		var (
+			edef *definition.Event
+			base time.Time
+		)
+		if ev == nil {
+			continue
+		}
+		if edef = e.def.Event(ev.Type(), ev.Name()); edef == nil || edef.Repeat == nil {
+			continue
+		}
+		// The next instance is based on when this one was scheduled or
+		// expected.  A set with no delay is never scheduled, so it is
+		// based on when it happened.
+		if base = ev.Expected(); base.IsZero() && ev.Type() == definition.EventSet {
+			base = ev.Occurred()
+		}
+		if base.IsZero() || base.After(e.st.Now()) {
+			continue // dropped, unscheduled, or not yet due
+		}
+		if e.st.GetEventByTrigger(ev.Type(), ev.Station(), ev.Name(), ev.ID()) != nil {
+			continue // already repeated
+		}
+		next := base.Add(edef.Repeat.Interval)
+		if !edef.Repeat.Allows(e.def.Exercise, e.instanceCount(ev), next) {
+			continue
+		}
+		if target := e.st.RepeatEvent(ev, next); target.Type() == definition.EventReceive && target.LMI() != "" && target.Occurred().IsZero() {
+			// This is a received message that came in before it was
+			// expected.  We'll treat it as received now, and then
+			// trigger its events.
+			e.st.ReceiveMessage(target.Station(), target.Name(), target.LMI(), target.RMI(), "", "")
+			e.runTriggers(target)
+		}
+	}
+}
+
+// instanceCount returns the number of instances of a repeating event up to and
+// including ev, by following the chain of triggers back to the first one.
+func (e *Engine) instanceCount(ev *state.Event) (count int) {
+	count = 1
+	for prev := e.st.GetEvent(ev.Trigger()); prev != nil && prev.Type() == ev.Type() && prev.Station() == ev.Station() && prev.Name() == ev.Name(); prev = e.st.GetEvent(prev.Trigger()) {
+		count++
+	}
+	return count
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/rothskeller/packet-ex/state"
@@ -10,7 +11,10 @@ import (
 )
 
 // sendMessage sends a message through the BBS connection.  Errors are logged.
-// Only errors that might be transient (i.e., JNOS errors) are returned.
+// Only errors that might be transient (i.e., JNOS errors) are returned.  ev is
+// the event for sending the message, which is dropped if the message can't be
+// sent.  It is nil for feedback messages, which don't have an event until
+// they're sent; for them, all errors are returned.
 func (e *Engine) sendMessage(conn BBSConnection, ev *state.Event, lmi string, env *envelope.Envelope, msg message.Message) (err error) {
 	env.Date = e.st.Now()
 	msg.SetOperator(e.def.Exercise.MyCall, e.def.Exercise.MyName, false)
@@ -18,10 +22,16 @@ func (e *Engine) sendMessage(conn BBSConnection, ev *state.Event, lmi string, en
 	var to []string
 	if addrs, err := envelope.ParseAddressList(env.To); err != nil {
 		e.st.LogError(fmt.Errorf("can't send %s: invalid To: address list", lmi))
+		if ev == nil {
+			return err
+		}
 		e.st.DropEvent(ev)
 		return nil
 	} else if len(addrs) == 0 {
 		e.st.LogError(fmt.Errorf("can't send %s: no To: addresses", lmi))
+		if ev == nil {
+			return errors.New("no To: addresses")
+		}
 		e.st.DropEvent(ev)
 		return nil
 	} else {
@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet-ex/state"
+)
+
+// doSet performs a set event for a station: it computes the new value of the
+// variable and records it.  It returns the resulting event, or nil if the
+// event had already occurred.
+func (e *Engine) doSet(edef *definition.Event, station string, triggers ...int) *state.Event {
+	var value = e.generateValue(edef.Value, station)
+	return e.st.SetVariable(station, edef.Name, edef.Variable, value, triggers...)
+}
+
+// runSets performs any set events that were scheduled with a delay and are now
+// due.
+func (e *Engine) runSets() {
+	for {
+		var ev *state.Event
+
+		if ev = e.st.PendingEvent(definition.EventSet); ev == nil {
+			break
+		}
+		edef := e.def.Event(definition.EventSet, ev.Name())
+		if edef == nil {
+			// The event was removed from the definition after it
+			// was scheduled.
+			e.st.DropEvent(ev)
+			continue
+		}
+		if ev = e.doSet(edef, ev.Station(), ev.Triggers()...); ev == nil {
+			continue
+		}
+		if err := e.runTriggers(ev); err != nil {
+			e.st.LogError(err)
+		}
+	}
+}
@@ -16,8 +16,14 @@ func (e *Engine) ClockTick(tick time.Time) {
 		e.startExercise()
 	}
 	e.runBbsSession()
+	e.runSets()
 	e.generateInjects()
-	e.st.MarkOverdueEvents(tick)
+	e.scheduleRepeats()
+	e.runTimeTriggers(tick)
+	for _, ev := range e.st.MarkOverdueEvents(tick) {
+		e.runOverdueTriggers(ev)
+	}
+	e.monitor.OnClockTick()
 }
 
 type BBSConnection interface {
@@ -63,12 +69,12 @@ func (e *Engine) runBbsSession() {
 		if err = e.sendMessage(conn, ev, lmi, env, msg); err != nil {
 			return // transient error, retry next tick
 		}
-		e.st.SendMessage(definition.EventBulletin, "", ev.Name(), lmi, env.SubjectLine, ev.Trigger())
+		e.st.SendMessage(definition.EventBulletin, "", ev.Name(), lmi, env.SubjectLine, ev.Triggers()...)
 		if err = e.runTriggers(ev); err != nil {
 			e.st.LogError(err)
 		}
 		for _, s := range e.def.Stations {
-			sev := e.st.SendMessage(definition.EventBulletin, s.CallSign, ev.Name(), lmi, env.SubjectLine, ev.Trigger())
+			sev := e.st.SendMessage(definition.EventBulletin, s.CallSign, ev.Name(), lmi, env.SubjectLine, ev.Triggers()...)
 			if err = e.runTriggers(sev); err != nil {
 				e.st.LogError(err)
 			}
@@ -85,7 +91,7 @@ func (e *Engine) runBbsSession() {
 		if err = e.sendMessage(conn, ev, lmi, env, msg); err != nil {
 			return // transient error, retry next tick
 		}
-		e.st.SendMessage(ev.Type(), ev.Station(), ev.Name(), lmi, env.SubjectLine, ev.Trigger())
+		e.st.SendMessage(ev.Type(), ev.Station(), ev.Name(), lmi, env.SubjectLine, ev.Triggers()...)
 		if err = e.runTriggers(ev); err != nil {
 			e.st.LogError(err)
 		}
@@ -118,3 +124,34 @@ func (e *Engine) generateInjects() {
 		}
 	}
 }
+
+// runTimeTriggers fires the "at" triggers whose times have been reached, both
+// globally and for each started station, unless they have already fired.  A
+// station started after the trigger time gets the trigger on the first tick
+// after it starts.
+func (e *Engine) runTimeTriggers(tick time.Time) {
+	var fired = make(map[string]bool)
+	for _, edef := range e.def.Events {
+		if edef.TriggerType != definition.EventAt || fired[edef.TriggerName] || edef.TriggerTime.After(tick) {
+			continue
+		}
+		fired[edef.TriggerName] = true
+		e.fireTimeTrigger("", edef.TriggerName)
+		for _, stn := range e.def.Stations {
+			if e.st.StationStarted(stn.CallSign) {
+				e.fireTimeTrigger(stn.CallSign, edef.TriggerName)
+			}
+		}
+	}
+}
+
+// fireTimeTrigger fires an "at" trigger for a station (or globally, if station
+// is empty), if it hasn't already fired.
+func (e *Engine) fireTimeTrigger(station, name string) {
+	if e.st.FindEvent(definition.EventAt, station, name) != nil {
+		return
+	}
+	if err := e.runTriggers(e.st.ReachTriggerTime(station, name)); err != nil {
+		e.st.LogError(err)
+	}
+}
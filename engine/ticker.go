@@ -8,7 +8,9 @@ import (
 
 // StartTicker computes the time at which the ticker should start, creates it,
 // and returns its output channel.  If the ticker won't start for a while, it
-// emits a notice to that effect.
+// emits a notice to that effect.  If a speed has been set with SetSpeed, the
+// ticker runs on a virtual clock that starts at the ticker start time
+// (immediately), and the state tracker is set to use the same clock.
 func (e *Engine) StartTicker() <-chan time.Time {
 	var start time.Time
 
@@ -27,13 +29,18 @@ func (e *Engine) StartTicker() <-chan time.Time {
 		start = time.Now()
 		start = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), 0, 0, start.Location())
 	}
-	if time.Until(start) > time.Minute {
+	if e.speed > 1 {
+		e.clock = newClock(start, e.speed)
+		e.st.SetNowFunc(e.clock.Now)
+		e.monitor.SetSpeed(e.speed)
+	}
+	if e.clock.Until(start) > time.Minute {
 		// The engine isn't going to start right away.  Make that
 		// obvious.
 		fmt.Fprintf(os.Stderr, "NOTICE: engine won't start until OpStart: %s\n",
 			e.def.Exercise.OpStart.Format("2006-01-02 15:04"))
 	}
-	return newTicker(start, 0)
+	return newTicker(start, 0, e.clock)
 }
 
 // newTicker creates a new ticker channel and returns it.  The times sent on
@@ -42,8 +49,9 @@ func (e *Engine) StartTicker() <-chan time.Time {
 // the first tick happens immediately.  Subsequent ticks will happen at (or as
 // soon as possible after) catchupDelay after the previous tick, but in no case
 // before the actual time in the tick.  The channel is unbuffered, so no tick
-// will be delivered until calling code is waiting for it.
-func newTicker(start time.Time, catchupDelay time.Duration) <-chan time.Time {
+// will be delivered until calling code is waiting for it.  All times, including
+// catchupDelay, are measured on the supplied clock (real time if nil).
+func newTicker(start time.Time, catchupDelay time.Duration, clock *clock) <-chan time.Time {
 	var ch = make(chan time.Time)
 
 	if start.Second() != 0 || start.Nanosecond() != 0 {
@@ -52,11 +60,11 @@ func newTicker(start time.Time, catchupDelay time.Duration) <-chan time.Time {
 	go func() {
 		var nextTick = start
 		for {
-			var delay = time.Until(nextTick)
+			var delay = clock.Until(nextTick)
 			if delay > 0 {
 				time.Sleep(delay)
 			}
-			nextTick = time.Now().Add(catchupDelay)
+			nextTick = clock.Now().Add(catchupDelay)
 			ch <- start
 			start = start.Add(time.Minute)
 			if nextTick.Before(start) {
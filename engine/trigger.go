@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"slices"
+
 	"github.com/rothskeller/packet-ex/definition"
 	"github.com/rothskeller/packet-ex/state"
 )
@@ -18,6 +20,11 @@ func (e *Engine) runTriggers(ev *state.Event) (err error) {
 	return nil
 }
 func (e *Engine) runTriggersForOne(trigger *state.Event) (cascade []*state.Event, err error) {
+	// If the triggering event was overdue, cancel any follow-up events
+	// triggered by that which haven't happened yet.
+	if trigger.Overdue() {
+		e.cancelOverdueFollowUps(trigger)
+	}
 	// Expect a delivery receipt if the triggering event sent a message to a
 	// station with a delivery receipt delay time.
 	if trigger.Type() == definition.EventSend {
@@ -29,47 +36,137 @@ func (e *Engine) runTriggersForOne(trigger *state.Event) (cascade []*state.Event
 	for _, edef := range e.def.Events {
 		if add, err := e.maybeTriggerEvent(trigger, edef); err != nil {
 			return nil, err
-		} else if add != nil {
-			cascade = append(cascade, add)
+		} else {
+			cascade = append(cascade, add...)
 		}
 	}
 	return cascade, err
 }
-func (e *Engine) maybeTriggerEvent(trigger *state.Event, edef *definition.Event) (cascade *state.Event, err error) {
+func (e *Engine) maybeTriggerEvent(trigger *state.Event, edef *definition.Event) (cascade []*state.Event, err error) {
 	// Is edef triggered by the event we're running triggers for?
-	if edef.TriggerType != trigger.Type() || edef.TriggerName != trigger.Name() {
+	if !edef.IsTriggeredBy(trigger.Type(), trigger.Name(), trigger.Station() == "") {
 		return
 	}
-	// Is there a condition on the triggering of edef, and is it met?
-	if !e.triggerConditionMet(edef, trigger) {
+	// Is it limited to a particular station, or to the happening that
+	// reaches a quorum of stations, and is that limit met?
+	if edef.TriggerStation != "" && trigger.Station() != edef.TriggerStation {
 		return
 	}
-	// Bulletin events can only be triggered globally, and are the only
-	// events that can be triggered globally.
-	if (edef.Type == definition.EventBulletin) != (trigger.Station() == "") {
+	if edef.TriggerQuorum != 0 && !e.quorumReached(trigger, edef) {
 		return
 	}
+	// If it has multiple triggers, is this the one that sets it off?
+	var joined []int
+	if edef.Triggers != nil {
+		var ok bool
+		if joined, ok = e.joinTriggers(trigger, edef); !ok {
+			return
+		}
+	}
+	// Bulletins happen globally and for all stations.  Other events with
+	// a trigger station or quorum happen for every station that has
+	// started.  Everything else happens for the station of the triggering
+	// event.
+	if edef.Type == definition.EventBulletin {
+		if add := e.triggerEvent(trigger, nil, edef, trigger.Station()); add != nil {
+			cascade = append(cascade, add)
+		}
+		return cascade, nil
+	}
+	var stations = []string{trigger.Station()}
+	if edef.CrossStation() {
+		stations = stations[:0]
+		for _, stn := range e.def.Stations {
+			if e.st.StationStarted(stn.CallSign) {
+				stations = append(stations, stn.CallSign)
+			}
+		}
+	}
+	for _, station := range stations {
+		if add := e.triggerEvent(trigger, joined, edef, station); add != nil {
+			cascade = append(cascade, add)
+		}
+	}
+	return cascade, nil
+}
+
+// runOverdueTriggers triggers the events that are triggered by ev becoming
+// overdue, and any events that cascade from them.
+func (e *Engine) runOverdueTriggers(ev *state.Event) {
+	for _, edef := range e.def.Events {
+		if !edef.IsTriggeredByOverdue(ev.Type(), ev.Name()) {
+			continue
+		}
+		if add := e.triggerEvent(ev, nil, edef, ev.Station()); add != nil {
+			if err := e.runTriggers(add); err != nil {
+				e.st.LogError(err)
+			}
+		}
+	}
+}
+
+// cancelOverdueFollowUps drops any pending events that were triggered by ev
+// becoming overdue, now that it has happened after all.
+func (e *Engine) cancelOverdueFollowUps(ev *state.Event) {
+	for _, fu := range e.st.AllEvents() {
+		if fu.Trigger() != ev.ID() || !fu.Occurred().IsZero() || fu.Expected().IsZero() {
+			continue
+		}
+		if edef := e.def.Event(fu.Type(), fu.Name()); edef != nil && edef.IsTriggeredByOverdue(ev.Type(), ev.Name()) {
+			e.st.DropEvent(fu)
+		}
+	}
+}
+
+// triggerEvent triggers the event edef for the specified station, as a result
+// of the trigger event, if its condition (evaluated for that station) is met.
+// joined lists the IDs of any other events it was waiting for.  It returns the
+// resulting event if it has already happened and its own triggers need to be
+// run.
+func (e *Engine) triggerEvent(trigger *state.Event, joined []int, edef *definition.Event, station string) (cascade *state.Event) {
+	var triggers = append(joined, trigger.ID())
+	// The delay is counted from when the trigger happened, or, for an
+	// overdue trigger, from when it was due.
+	var base = trigger.Occurred()
+	if edef.TriggerOverdue {
+		base = trigger.Expected()
+	}
+	var delay = e.delay(edef, station, triggers)
+
+	// Is there a condition on the triggering of edef, and is it met?
+	if !edef.ConditionMet(func(vname string) (string, bool) { return e.conditionVariable(vname, station, trigger) }) {
+		return nil
+	}
 	// Schedule or expect the event, depending on its type.
 	switch edef.Type {
 	case definition.EventBulletin:
 		// On trigger of a global bulletin, schedule it both globally
 		// and for all defined stations.
-		e.st.ScheduleEvent(edef.Type, "", edef.Name, trigger.Occurred().Add(edef.Delay), trigger.ID())
+		e.st.ScheduleEvent(edef.Type, "", edef.Name, base.Add(delay), triggers...)
 		for _, stn := range e.def.Stations {
-			e.st.ScheduleEvent(edef.Type, stn.CallSign, edef.Name, trigger.Occurred().Add(edef.Delay), trigger.ID())
+			e.st.ScheduleEvent(edef.Type, stn.CallSign, edef.Name, base.Add(delay), triggers...)
 		}
 	case definition.EventInject, definition.EventSend:
 		// On trigger of an inject or send, schedule it.
-		e.st.ScheduleEvent(edef.Type, trigger.Station(), edef.Name, trigger.Occurred().Add(edef.Delay), trigger.ID())
+		e.st.ScheduleEvent(edef.Type, station, edef.Name, base.Add(delay), triggers...)
+	case definition.EventSet:
+		// On trigger of a set, do it right away if there's no delay,
+		// so that the events it triggers see the new value.
+		// Otherwise, schedule it.
+		if delay == 0 {
+			cascade = e.doSet(edef, station, triggers...)
+		} else {
+			e.st.ScheduleEvent(edef.Type, station, edef.Name, base.Add(delay), triggers...)
+		}
 	case definition.EventAlert, definition.EventDeliver, definition.EventReceive:
 		// On trigger of an alert, deliver, or receive, add the
 		// expectation for it.
-		target := e.st.ExpectEvent(edef.Type, trigger.Station(), edef.Name, trigger.Occurred().Add(edef.Delay), trigger.ID())
+		target := e.st.ExpectEvent(edef.Type, station, edef.Name, base.Add(delay), triggers...)
 		if target.LMI() != "" && target.Occurred().IsZero() {
 			// This is a received message that came in before it was
 			// expected.  We'll treat it as received now, and then
 			// trigger its events.
-			e.st.ReceiveMessage(target.Station(), target.Name(), target.LMI(), "", "")
+			e.st.ReceiveMessage(target.Station(), target.Name(), target.LMI(), target.RMI(), "", "")
 			e.runTriggers(target)
 		}
 	default:
@@ -77,34 +174,61 @@ func (e *Engine) maybeTriggerEvent(trigger *state.Event, edef *definition.Event)
 		// through, so this is a software bug.
 		panic("unexpected event type in runTriggers")
 	}
-	return cascade, err
+	return cascade
 }
 
-func (e *Engine) triggerConditionMet(te *definition.Event, ev *state.Event) bool {
-	if te.ConditionVar == "" {
-		return true
-	}
-	have, ok := e.Variable(te.ConditionVar, ev.Station())
-	if !ok {
-		return false
-	}
-	switch te.ConditionOp {
-	case "=":
-		return have == te.ConditionVal
-	case "!=":
-		return have != te.ConditionVal
-	case "<":
-		return have < te.ConditionVal
-	case "<=":
-		return have <= te.ConditionVal
-	case ">":
-		return have > te.ConditionVal
-	case ">=":
-		return have >= te.ConditionVal
-	case "≈":
-		return te.ConditionRE.MatchString(have)
-	}
-	// Definition parser shouldn't let anything else through, so this is a
-	// software bug.
-	panic("not reachable")
+// quorumReached returns whether the trigger event is the one that brings the
+// number of stations for which it has happened up to the quorum required by
+// edef.  Only the first happening for each station counts.
+func (e *Engine) quorumReached(trigger *state.Event, edef *definition.Event) bool {
+	var count int
+
+	for _, stn := range e.def.Stations {
+		for _, ev := range e.st.FindEvents(trigger.Type(), stn.CallSign, trigger.Name()) {
+			if ev.ID() == trigger.ID() || ev.Occurred().IsZero() {
+				continue
+			}
+			if stn.CallSign == trigger.Station() {
+				return false // not the first happening for this station
+			}
+			count++
+			break
+		}
+	}
+	return count+1 == edef.Quorum(len(e.def.Stations))
+}
+
+// joinTriggers handles an event edef with multiple triggers, when one of them
+// (trigger) happens.  If edef needs all of its triggers, it returns the IDs of
+// the latest happenings of the others for the same station, and true if they
+// have all happened.  If edef needs any one of them, it returns true if none of
+// the others has happened for the same station, i.e., if this is the first.
+func (e *Engine) joinTriggers(trigger *state.Event, edef *definition.Event) (joined []int, ok bool) {
+	var others []*state.Event
+
+	for _, t := range edef.Triggers {
+		if t.Type == trigger.Type() && t.Name == trigger.Name() {
+			continue
+		}
+		var latest *state.Event
+		for _, ev := range e.st.FindEvents(t.Type, trigger.Station(), t.Name) {
+			if !ev.Occurred().IsZero() && (latest == nil || ev.Occurred().After(latest.Occurred())) {
+				latest = ev
+			}
+		}
+		switch {
+		case edef.AllTriggers && latest == nil:
+			return nil, false
+		case edef.AllTriggers:
+			others = append(others, latest)
+		case latest != nil:
+			return nil, false
+		}
+	}
+	// The IDs are recorded in the order the events happened.
+	slices.SortStableFunc(others, func(a, b *state.Event) int { return a.Occurred().Compare(b.Occurred()) })
+	for _, ev := range others {
+		joined = append(joined, ev.ID())
+	}
+	return joined, true
 }
@@ -1,8 +1,11 @@
 package engine
 
 import (
+	"strconv"
 	"strings"
 
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet-ex/state"
 	"github.com/rothskeller/packet/envelope"
 	"github.com/rothskeller/packet/incident"
 	"github.com/rothskeller/packet/message"
@@ -20,9 +23,16 @@ func (e *Engine) Variable(name, station string) (value string, ok bool) {
 	group, item, _ := strings.Cut(name, ".")
 	switch group {
 	case "exercise":
+		// A value assigned by a set event overrides the definition.
+		if value, ok = e.st.Variable(station, name); ok {
+			return value, ok
+		}
 		value, ok = e.def.Exercise.Variables[item]
 		return value, ok
 	case "station":
+		if value, ok = e.st.Variable(station, name); ok {
+			return value, ok
+		}
 		if stn := e.def.Station(station); stn == nil {
 			return "", false
 		} else {
@@ -41,7 +51,31 @@ func (e *Engine) Variable(name, station string) (value string, ok bool) {
 			return "", false
 		}
 	}
-	ev := e.st.GetSendReceiveEventByStationName(station, group)
+	return messageVariable(e.st.GetSendReceiveEventByStationName(station, group), item)
+}
+
+// conditionVariable returns the value of a variable in the condition of an
+// event being triggered for station by trigger.  In addition to the variables supported by
+// Variable, conditions can use trigger.XXX variables, which refer to the
+// triggering event.
+func (e *Engine) conditionVariable(name, station string, trigger *state.Event) (value string, ok bool) {
+	group, item, _ := strings.Cut(name, ".")
+	if group != "trigger" {
+		return e.Variable(name, station)
+	}
+	if item == "score" {
+		// Only received messages are scored.
+		if trigger.Type() == definition.EventReceive && trigger.LMI() != "" {
+			return strconv.Itoa(trigger.Score()), true
+		}
+		return "", false
+	}
+	return messageVariable(trigger, item)
+}
+
+// messageVariable returns the value of an item from the message exchanged in
+// the specified event.
+func messageVariable(ev *state.Event, item string) (value string, ok bool) {
 	if ev == nil || ev.LMI() == "" {
 		return "", false
 	}
@@ -67,6 +101,13 @@ func (e *Engine) Variable(name, station string) (value string, ok bool) {
 		if !ev.Occurred().IsZero() {
 			return ev.Occurred().Format("01/02/2006 15:04"), true
 		}
+	default:
+		// Any other item is the label of a field of the message.
+		for _, f := range msg.Base().Fields {
+			if f.Label == item && f.Value != nil {
+				return *f.Value, true
+			}
+		}
 	}
 	return "", false
 }
@@ -0,0 +1,50 @@
+package fakebbs
+
+import "slices"
+
+// A Connection is an in-process stand-in for a connection to a single JNOS
+// mailbox, for running the exercise engine without any BBS at all (e.g., in
+// simulations and replays).  It has the methods of engine.BBSConnection.
+// Mailbox contains the raw messages waiting to be read.  Messages sent through
+// the connection are passed to OnSend, or discarded if it is nil.  Like JNOS,
+// killed messages retain their message numbers until the connection is closed.
+type Connection struct {
+	Mailbox []string
+	OnSend  func(subject, body string, to []string) error
+	killed  []int
+}
+
+// Read returns the raw text of the message with the specified number, or an
+// empty string if there is no such message or it has been killed.
+func (c *Connection) Read(msgnum int) (string, error) {
+	if msgnum < 1 || msgnum > len(c.Mailbox) || slices.Contains(c.killed, msgnum) {
+		return "", nil
+	}
+	return c.Mailbox[msgnum-1], nil
+}
+
+// Kill kills the messages with the specified numbers.
+func (c *Connection) Kill(msgnums ...int) error {
+	c.killed = append(c.killed, msgnums...)
+	return nil
+}
+
+// Send sends a message.
+func (c *Connection) Send(subject, body string, to ...string) error {
+	if c.OnSend != nil {
+		return c.OnSend(subject, body, to)
+	}
+	return nil
+}
+
+// Close closes the connection, removing killed messages from the mailbox.
+func (c *Connection) Close() error {
+	var keep []string
+	for i, raw := range c.Mailbox {
+		if !slices.Contains(c.killed, i+1) {
+			keep = append(keep, raw)
+		}
+	}
+	c.Mailbox, c.killed = keep, nil
+	return nil
+}
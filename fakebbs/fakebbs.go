@@ -0,0 +1,420 @@
+// Package fakebbs is a local stand-in for a JNOS BBS.  It speaks enough of the
+// JNOS telnet mailbox dialect for the exercise engine (and other packet
+// clients) to log in, read, kill, and send messages, so that an exercise can be
+// run end to end without a radio or a real BBS.
+//
+// Every mailbox and bulletin area is created on first use.  Messages are
+// delivered to the mailbox named by the local part of each recipient address,
+// regardless of the host part; nothing is ever forwarded.  As in JNOS, messages
+// in an area are numbered from 1 when the area is entered, and killed messages
+// keep their numbers until the area is left or the session ends.
+//
+// For running the engine without any BBS server at all, Connection is an
+// in-process stand-in for a connection to a single mailbox.
+package fakebbs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A BBS is a fake JNOS BBS.
+type BBS struct {
+	// Name is the call sign of the BBS.
+	Name string
+	// Passwords, if not nil, maps lowercase user names to the passwords
+	// they must log in with.  Users not in the map can log in with any
+	// password.
+	Passwords map[string]string
+	// Now is the function used to determine the current time when dating
+	// new messages.  It defaults to time.Now.
+	Now func() time.Time
+
+	areas map[string][]*bmessage
+	msgid int
+	mutex sync.Mutex
+}
+
+// A bmessage is a single message stored on the BBS.
+type bmessage struct {
+	from    string
+	to      string
+	subject string
+	date    time.Time
+	raw     string
+	read    bool
+	killed  bool
+}
+
+// New creates a new fake BBS with the specified call sign.
+func New(name string) *BBS {
+	return &BBS{Name: strings.ToUpper(name), Now: time.Now, areas: make(map[string][]*bmessage)}
+}
+
+// ListenAndServe listens on the specified TCP address and serves telnet
+// connections on it.  It does not return unless the listen fails.
+func (b *BBS) ListenAndServe(addr string) (err error) {
+	var listener net.Listener
+
+	if listener, err = net.Listen("tcp", addr); err != nil {
+		return err
+	}
+	return b.Serve(listener)
+}
+
+// Serve serves telnet connections on the supplied listener.  It returns when
+// the listener is closed.
+func (b *BBS) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go b.serveConn(conn)
+	}
+}
+
+// Deliver puts a message on the BBS as if it had been sent by the specified
+// user.  to is a list of addresses; the local part of each is the name of the
+// mailbox or bulletin area that receives a copy.
+func (b *BBS) Deliver(from, subject, body string, to ...string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.deliver(from, subject, body, to)
+}
+
+func (b *BBS) deliver(from, subject, body string, to []string) {
+	var date = b.Now()
+
+	if !strings.Contains(from, "@") {
+		from = fmt.Sprintf("%s@%s.ampr.org", strings.ToLower(from), strings.ToLower(b.Name))
+	}
+	for _, addr := range to {
+		area, _, _ := strings.Cut(strings.ToLower(addr), "@")
+		b.msgid++
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Received: from %s by %s.ampr.org (JNOS2.0) with SMTP\n", strings.ToLower(b.Name), strings.ToLower(b.Name))
+		fmt.Fprintf(&sb, "\tid AA%d ; %s\n", b.msgid, date.Format(time.RFC1123Z))
+		fmt.Fprintf(&sb, "Date: %s\n", date.Format(time.RFC1123Z))
+		fmt.Fprintf(&sb, "Message-ID: <%d@%s.ampr.org>\n", b.msgid, strings.ToLower(b.Name))
+		fmt.Fprintf(&sb, "From: %s\n", from)
+		fmt.Fprintf(&sb, "To: %s\n", addr)
+		fmt.Fprintf(&sb, "Subject: %s\n\n", subject)
+		sb.WriteString(body)
+		if !strings.HasSuffix(body, "\n") {
+			sb.WriteByte('\n')
+		}
+		b.areas[area] = append(b.areas[area], &bmessage{
+			from: from, to: addr, subject: subject, date: date, raw: sb.String(),
+		})
+	}
+}
+
+// Messages returns the raw text of the messages in the named mailbox or
+// bulletin area.
+func (b *BBS) Messages(area string) (raws []string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, m := range b.areas[strings.ToLower(area)] {
+		raws = append(raws, m.raw)
+	}
+	return raws
+}
+
+// A session is a single telnet connection to the BBS.
+type session struct {
+	b      *BBS
+	conn   net.Conn
+	in     *bufio.Reader
+	user   string
+	area   string
+	expert bool
+}
+
+func (b *BBS) serveConn(conn net.Conn) {
+	var s = session{b: b, conn: conn, in: bufio.NewReader(conn)}
+
+	defer conn.Close()
+	if !s.login() {
+		return
+	}
+	defer s.leaveArea()
+	s.enterArea(s.user)
+	s.writef("[JNOS-2.0-B1FHIM$]\n")
+	s.prompt()
+	for {
+		line, ok := s.readLine()
+		if !ok {
+			return
+		}
+		if !s.command(line) {
+			return
+		}
+	}
+}
+
+// login handles the telnet login sequence.  It returns whether the login was
+// successful.
+func (s *session) login() bool {
+	s.writef("JNOS (%s)\n\nlogin: ", strings.ToLower(s.b.Name))
+	user, ok := s.readLine()
+	if !ok || user == "" {
+		return false
+	}
+	s.writef("Password: ")
+	password, ok := s.readLine()
+	if !ok {
+		return false
+	}
+	s.user = strings.ToLower(user)
+	if want, ok := s.b.Passwords[s.user]; ok && want != password {
+		s.writef("Login incorrect\n")
+		return false
+	}
+	return true
+}
+
+// command executes a single mailbox command.  It returns false if the session
+// should end.
+func (s *session) command(line string) bool {
+	var verb, args, _ = strings.Cut(strings.TrimSpace(line), " ")
+	args = strings.TrimSpace(args)
+	switch strings.ToUpper(verb) {
+	case "":
+		break
+	case "A", "AREA":
+		if args != "" {
+			s.leaveArea()
+			s.enterArea(strings.ToLower(args))
+		}
+		s.writef("Area: %s Current msg# %d.\n", s.area, s.count())
+	case "B", "BYE":
+		s.writef("Thank you %s, for calling JNOS.\n", s.user)
+		return false
+	case "K", "KM":
+		s.kill(args)
+	case "L", "LA", "LB", "LM", "LL":
+		s.list()
+	case "R", "RM", "V", "VM":
+		s.read(args)
+	case "S", "SP", "SB", "ST":
+		if !s.send(strings.ToUpper(verb), args) {
+			return false
+		}
+	case "X":
+		s.expert = !s.expert
+		if s.expert {
+			s.writef("Expert ON\n")
+		} else {
+			s.writef("Expert OFF\n")
+		}
+	default:
+		// Other commands (XM, XA, SID lines, etc.) are accepted and
+		// ignored.
+	}
+	s.prompt()
+	return true
+}
+
+// enterArea makes the named area current.  All message numbers are assigned
+// at this point.
+func (s *session) enterArea(area string) {
+	s.b.mutex.Lock()
+	defer s.b.mutex.Unlock()
+	s.area = area
+	s.b.compact(area)
+}
+
+// leaveArea removes the killed messages from the current area.
+func (s *session) leaveArea() {
+	s.b.mutex.Lock()
+	defer s.b.mutex.Unlock()
+	s.b.compact(s.area)
+}
+
+func (b *BBS) compact(area string) {
+	var keep []*bmessage
+	for _, m := range b.areas[area] {
+		if !m.killed {
+			keep = append(keep, m)
+		}
+	}
+	b.areas[area] = keep
+}
+
+// count returns the number of messages in the current area, including killed
+// ones (which still hold their message numbers).
+func (s *session) count() int {
+	s.b.mutex.Lock()
+	defer s.b.mutex.Unlock()
+	return len(s.b.areas[s.area])
+}
+
+// message returns the message with the specified number (a string from a
+// command argument) in the current area, or nil if there is no such message.
+func (s *session) message(arg string) (num int, m *bmessage) {
+	var err error
+	if num, err = strconv.Atoi(arg); err != nil {
+		return 0, nil
+	}
+	if num < 1 || num > len(s.b.areas[s.area]) || s.b.areas[s.area][num-1].killed {
+		return num, nil
+	}
+	return num, s.b.areas[s.area][num-1]
+}
+
+func (s *session) read(args string) {
+	s.b.mutex.Lock()
+	defer s.b.mutex.Unlock()
+	for _, arg := range strings.Fields(args) {
+		num, m := s.message(arg)
+		if m == nil {
+			s.writef("Invalid message number %s\n", arg)
+			continue
+		}
+		m.read = true
+		s.writef("Message #%d \n%s\n", num, m.raw)
+	}
+}
+
+func (s *session) kill(args string) {
+	s.b.mutex.Lock()
+	defer s.b.mutex.Unlock()
+	for _, arg := range strings.Fields(args) {
+		num, m := s.message(arg)
+		if m == nil {
+			s.writef("Invalid message number %s\n", arg)
+			continue
+		}
+		m.killed = true
+		s.writef("Msg %d Killed.\n", num)
+	}
+}
+
+func (s *session) list() {
+	s.b.mutex.Lock()
+	defer s.b.mutex.Unlock()
+	var msgs = s.b.areas[s.area]
+	var live, unread int
+	for _, m := range msgs {
+		if !m.killed {
+			live++
+			if !m.read {
+				unread++
+			}
+		}
+	}
+	s.writef("Mail area: %s\n%d messages  -  %d new\n\n", s.area, live, unread)
+	if live == 0 {
+		return
+	}
+	s.writef("St.  #  TO            FROM     DATE   SIZE SUBJECT\n")
+	for i, m := range msgs {
+		if m.killed {
+			continue
+		}
+		st := "N"
+		if m.read {
+			st = "Y"
+		}
+		from, to := m.from, m.to
+		if addr, err := mail.ParseAddress(from); err == nil {
+			from = addr.Address
+		}
+		from, _, _ = strings.Cut(from, "@")
+		to, _, _ = strings.Cut(to, "@")
+		s.writef("  %s %3d %-13.13s %-8.8s %s %4d %s\n", st, i+1, to, from, m.date.Format("Jan 02"), len(m.raw), m.subject)
+	}
+}
+
+// send handles the SP, SB, and ST commands.  It returns false if the
+// connection was lost while reading the message.
+func (s *session) send(verb, args string) bool {
+	var (
+		to      = strings.Fields(args)
+		subject string
+		body    strings.Builder
+		ok      bool
+	)
+	if len(to) == 0 {
+		s.writef("%s command requires an address\n", verb)
+		return true
+	}
+	s.writef("Subject:\n")
+	if subject, ok = s.readLine(); !ok {
+		return false
+	}
+	s.writef("Enter message.  End with /EX or ^Z in first column (^A aborts):\n")
+	for {
+		line, ok := s.readLine()
+		if !ok {
+			return false
+		}
+		if strings.HasPrefix(line, "\x01") {
+			s.writef("Aborted.\n")
+			return true
+		}
+		if strings.EqualFold(line, "/EX") || strings.HasPrefix(line, "\x1A") {
+			break
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	s.b.mutex.Lock()
+	s.b.deliver(s.user, subject, body.String(), to)
+	s.b.mutex.Unlock()
+	s.writef("Msg queued\n")
+	return true
+}
+
+func (s *session) prompt() {
+	if s.expert {
+		s.writef("(#%d) >\n", s.count())
+	} else {
+		s.writef("Area: %s Current msg# %d.\n?,A,B,C,CONV,D,E,F,H,I,IH,IP,J,K,L,M,N,NR,O,P,PI,R,S,SR,T,U,V,W,X,Z >\n", s.area, s.count())
+	}
+}
+
+// writef writes formatted text to the connection, translating newlines to the
+// CRLF that telnet expects.
+func (s *session) writef(f string, args ...any) {
+	text := fmt.Sprintf(f, args...)
+	io.WriteString(s.conn, strings.ReplaceAll(text, "\n", "\r\n"))
+}
+
+// readLine reads a line from the connection, removing any telnet option
+// negotiation and the line terminator.  It returns false if the connection
+// was closed.
+func (s *session) readLine() (line string, ok bool) {
+	var sb strings.Builder
+	for {
+		c, err := s.in.ReadByte()
+		if err != nil {
+			return "", false
+		}
+		switch c {
+		case 0xFF: // IAC
+			if c, err = s.in.ReadByte(); err != nil {
+				return "", false
+			}
+			if c >= 0xFB && c <= 0xFE { // WILL, WONT, DO, DONT
+				if _, err = s.in.ReadByte(); err != nil {
+					return "", false
+				}
+			}
+		case '\r', 0:
+			// ignore
+		case '\n':
+			return sb.String(), true
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,16 +24,26 @@ func main() {
 		e       *engine.Engine
 		err     error
 		offline = flag.Bool("offline", false, "offline mode: no BBS connections, etc.")
+		speedf  = flag.String("speed", "1x", "clock speed for rehearsals, as a multiple of real time (e.g. 30x)")
+		speed   float64
 	)
 	// Read the command line for the exercise definition filename.
 	flag.Parse()
+	if flag.Arg(0) == "replay" {
+		replay(flag.Args()[1:])
+		return
+	}
+	if speed, err = strconv.ParseFloat(strings.TrimSuffix(*speedf, "x"), 64); err != nil || speed < 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid -speed %q\n", *speedf)
+		os.Exit(2)
+	}
 	switch len(flag.Args()) {
 	case 0:
 		fname = "exercise.def"
 	case 1:
 		fname = flag.Arg(0)
 	default:
-		fmt.Fprintln(os.Stderr, "usage: packet-ex [definition-file]")
+		fmt.Fprintln(os.Stderr, "usage: packet-ex [-offline] [-speed Nx] [replay] [definition-file]")
 		os.Exit(2)
 	}
 	// If the exercise definition file is in a different directory, make
@@ -59,8 +70,7 @@ func main() {
 		os.Exit(1)
 	}
 	// Read the exercise state.
-	fname = strings.TrimSuffix(fname, ".def") + ".log"
-	if err = st.Open(fname); err != nil {
+	if err = st.Open(strings.TrimSuffix(fname, ".def") + ".log"); err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		os.Exit(1)
 	}
@@ -77,11 +87,14 @@ func main() {
 			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 			os.Exit(1)
 		}
+		e.SetSpeed(speed)
 		e.SetTicker(e.StartTicker())
 		e.SetBBSConnector(func(ex *definition.Exercise) (engine.BBSConnection, error) {
 			return telnet.Connect(ex.BBSAddress, ex.MyCall, ex.BBSPassword, jnosLog)
 		})
 	}
+	// Watch for changes to the exercise definition.
+	e.WatchDefinition(fname)
 	// Run the engine.  No fatal errors should be possible past this point.
 	// (Panics may occur for software assertion errors only.)
 	e.Run()
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-ex/definition"
+	"github.com/rothskeller/packet-ex/engine"
+	"github.com/rothskeller/packet-ex/fakebbs"
+	"github.com/rothskeller/packet-ex/server"
+	"github.com/rothskeller/packet-ex/state"
+	"github.com/rothskeller/packet/xscmsg"
+)
+
+// A replayInput is something that happened in the original exercise that
+// didn't originate with the engine itself: a message received from the BBS, or
+// a manual trigger from the monitor.
+type replayInput struct {
+	at  time.Time
+	raw string                // raw text of received message
+	mt  *server.ManualTrigger // manual trigger
+}
+
+// replay re-runs a finished exercise.  It reads the exercise log, extracts the
+// messages received during the exercise and the manual triggers given, and
+// feeds them through a fresh engine with a virtual clock, in a "replay"
+// subdirectory of the exercise directory.  Then it compares the outcome of each
+// event in the new log against the original one.
+func replay(args []string) {
+	var (
+		fname   string
+		logname string
+		def     *definition.Definition
+		inputs  []*replayInput
+		start   time.Time
+		end     time.Time
+		seed    int64
+		st      *state.State
+		e       *engine.Engine
+		conn    *fakebbs.Connection
+		now     time.Time
+		err     error
+	)
+	switch len(args) {
+	case 0:
+		fname = "exercise.def"
+	case 1:
+		fname = args[0]
+	default:
+		fmt.Fprintln(os.Stderr, "usage: packet-ex replay [definition-file]")
+		os.Exit(2)
+	}
+	if dir := filepath.Dir(fname); dir != "." {
+		if err := os.Chdir(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		fname = filepath.Base(fname)
+	}
+	// Read the exercise definition.
+	xscmsg.Register()
+	if def, err = definition.Read(fname); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	// Don't collide with a running engine.
+	def.Exercise.ListenAddr = "localhost:0"
+	// Read the original exercise log to get the replay inputs.
+	logname = strings.TrimSuffix(fname, ".def") + ".log"
+	if inputs, start, end, seed, err = readReplayInputs(logname); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	if start.IsZero() {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: nothing to replay\n", logname)
+		os.Exit(1)
+	}
+	// Start with a clean replay directory, and move into it so that all
+	// incident files are saved there.
+	if err = os.RemoveAll("replay"); err == nil {
+		err = os.Mkdir("replay", 0777)
+	}
+	if err == nil {
+		err = os.Chdir("replay")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	// Create a state tracker whose clock advances by one millisecond every
+	// time it is read, so that log entries stay in order.
+	st = state.New(false)
+	st.SetNowFunc(func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	})
+	// Use the same random seed, so that random delays and message variants
+	// come out the same.
+	st.SetSeed(seed)
+	if e, err = engine.New(def, st); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	if err = st.Open(logname); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	conn = new(fakebbs.Connection) // messages sent are discarded
+	e.SetBBSConnector(func(*definition.Exercise) (engine.BBSConnection, error) { return conn, nil })
+	e.SetNoInject()
+	// Run a clock tick for every minute of the original exercise.
+	// Messages are available on the BBS at the tick for the minute in
+	// which they were originally received, and manual triggers are given
+	// after it.
+	for tick := start.Truncate(time.Minute); !tick.After(end); tick = tick.Add(time.Minute) {
+		var manual []*replayInput
+
+		for len(inputs) != 0 && inputs[0].at.Truncate(time.Minute).Equal(tick) {
+			if inputs[0].raw != "" {
+				conn.Mailbox = append(conn.Mailbox, inputs[0].raw)
+			} else {
+				manual = append(manual, inputs[0])
+			}
+			inputs = inputs[1:]
+		}
+		if tick.After(now) {
+			now = tick
+		}
+		e.ClockTick(tick)
+		for _, in := range manual {
+			if in.at.After(now) {
+				now = in.at
+			}
+			e.ManualTrigger(*in.mt)
+		}
+	}
+	// Compare the outcome with the original.
+	diffs, err := compareLogs(filepath.Join("..", logname), logname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) != 0 {
+		fmt.Printf("%d differences; replay log is in %s\n", len(diffs), filepath.Join("replay", logname))
+		os.Exit(1)
+	}
+	fmt.Printf("no differences; replay log is in %s\n", filepath.Join("replay", logname))
+}
+
+var (
+	replayReceivedRE = regexp.MustCompile(`^(\S+) \[(\d+)\] \S+ (?:receive|reject) \S+ (?:RECEIVED|RESENT|REJECTED) LMI (\S+)`)
+	replayReassignRE = regexp.MustCompile(`^(\S+) \[(\d+)\] \S+ reject \S+ REASSIGNED TO (\S+) (\S+)$`)
+	replayRecordedRE = regexp.MustCompile(`^(\S+) \[\d+\] (\S+) (alert|deliver|receive|set) (\S+) (?:RECORDED|SET \S+ "(?:[^"\\]|\\.)*")$`)
+	replayScheduleRE = regexp.MustCompile(`^(\S+) \[\d+\] (\S+) (bulletin|inject|send) (\S+) SCHEDULED \S+$`)
+	replaySeedRE     = regexp.MustCompile(`^\S+ \[1\] ALL start SEED (\d+)$`)
+)
+
+// readReplayInputs reads the original exercise log, and returns the list of
+// inputs to be replayed, in chronological order, the times of the first and
+// last log entries, and the random seed of the exercise.  Received messages are
+// read from the incident files saved alongside the log.  Events that were
+// recorded or scheduled without a trigger, and rejected messages that were
+// reassigned, were manual triggers.  A message recorded more than once (e.g.,
+// when it is reassigned) is only received once.
+func readReplayInputs(logname string) (inputs []*replayInput, start, end time.Time, seed int64, err error) {
+	var (
+		fh   *os.File
+		scan *bufio.Scanner
+		lnum int
+		lmis = make(map[string]string) // LMI by receive or reject event ID
+		seen = make(map[string]bool)   // LMIs already received
+	)
+	if fh, err = os.Open(logname); err != nil {
+		return nil, start, end, seed, err
+	}
+	defer fh.Close()
+	scan = bufio.NewScanner(fh)
+	for scan.Scan() {
+		var in replayInput
+
+		lnum++
+		line := scan.Text()
+		if tstamp, _, ok := strings.Cut(line, " "); ok {
+			if t, err := time.ParseInLocation("2006-01-02T15:04:05.000", tstamp, time.Local); err == nil {
+				if start.IsZero() {
+					start = t
+				}
+				end = t
+			}
+		}
+		if match := replaySeedRE.FindStringSubmatch(line); match != nil {
+			seed, _ = strconv.ParseInt(match[1], 10, 64)
+			continue
+		}
+		if match := replayReceivedRE.FindStringSubmatch(line); match != nil {
+			var raw []byte
+			lmis[match[2]] = match[3]
+			if seen[match[3]] {
+				continue
+			}
+			seen[match[3]] = true
+			if raw, err = os.ReadFile(match[3] + ".txt"); err != nil {
+				return nil, start, end, seed, fmt.Errorf("%s:%d: %s", logname, lnum, err)
+			}
+			in.raw = string(raw)
+			in.at, err = time.ParseInLocation("2006-01-02T15:04:05.000", match[1], time.Local)
+		} else if match = replayReassignRE.FindStringSubmatch(line); match != nil {
+			in.mt = &server.ManualTrigger{Type: definition.EventReceive, Station: match[3], Name: match[4], LMI: lmis[match[2]]}
+			in.at, err = time.ParseInLocation("2006-01-02T15:04:05.000", match[1], time.Local)
+		} else if match = replayRecordedRE.FindStringSubmatch(line); match != nil {
+			in.mt = &server.ManualTrigger{Station: match[2], Name: match[4]}
+			in.mt.Type, _ = definition.ParseEventType(match[3])
+			in.at, err = time.ParseInLocation("2006-01-02T15:04:05.000", match[1], time.Local)
+		} else if match = replayScheduleRE.FindStringSubmatch(line); match != nil {
+			in.mt = &server.ManualTrigger{Station: match[2], Name: match[4]}
+			if in.mt.Station == "ALL" {
+				in.mt.Station = ""
+			}
+			in.mt.Type, _ = definition.ParseEventType(match[3])
+			in.at, err = time.ParseInLocation("2006-01-02T15:04:05.000", match[1], time.Local)
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, start, end, seed, fmt.Errorf("%s:%d: bad timestamp", logname, lnum)
+		}
+		inputs = append(inputs, &in)
+	}
+	if err = scan.Err(); err != nil {
+		return nil, start, end, seed, fmt.Errorf("%s:%d: %s", logname, lnum, err)
+	}
+	slices.SortStableFunc(inputs, func(a, b *replayInput) int { return a.at.Compare(b.at) })
+	return inputs, start, end, seed, nil
+}
+
+// compareLogs compares the outcomes of the events in two exercise logs, and
+// returns a description of each difference.  Events are matched by type,
+// station, and message name (and by order, for those that can repeat).
+func compareLogs(original, replayed string) (diffs []string, err error) {
+	var oevents, revents map[string][]*state.Event
+
+	if oevents, err = readLogEvents(original); err != nil {
+		return nil, err
+	}
+	if revents, err = readLogEvents(replayed); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key := range oevents {
+		keys = append(keys, key)
+	}
+	for key := range revents {
+		if _, ok := oevents[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		oes, res := oevents[key], revents[key]
+		for i := 0; i < len(oes) || i < len(res); i++ {
+			switch {
+			case i >= len(res):
+				diffs = append(diffs, fmt.Sprintf("%s: not in replay", key))
+			case i >= len(oes):
+				diffs = append(diffs, fmt.Sprintf("%s: not in original", key))
+			default:
+				if d := compareEvents(oes[i], res[i]); d != "" {
+					diffs = append(diffs, fmt.Sprintf("%s: %s", key, d))
+				}
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// compareEvents compares the outcomes of an event in the original and replay
+// logs, and returns a description of the difference, or an empty string if
+// there is none.
+func compareEvents(o, r *state.Event) string {
+	var describe = func(e *state.Event) string {
+		switch {
+		case !e.Occurred().IsZero() && e.Score() != 0:
+			return fmt.Sprintf("occurred, score %d", e.Score())
+		case !e.Occurred().IsZero() && e.Type() == definition.EventSet:
+			return fmt.Sprintf("occurred, value %q", e.Value())
+		case !e.Occurred().IsZero():
+			return "occurred"
+		case e.Overdue():
+			return "overdue"
+		case !e.Expected().IsZero():
+			return "expected"
+		default:
+			return "pending"
+		}
+	}
+	if od, rd := describe(o), describe(r); od != rd {
+		return fmt.Sprintf("%s in original, %s in replay", od, rd)
+	}
+	return ""
+}
+
+// readLogEvents reads an exercise log into a throwaway state tracker, and
+// returns its events, grouped by type, station, and message name.
+func readLogEvents(fname string) (events map[string][]*state.Event, err error) {
+	var (
+		fh   *os.File
+		scan *bufio.Scanner
+		lnum int
+		st   = state.New(false)
+	)
+	if fh, err = os.Open(fname); err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	scan = bufio.NewScanner(fh)
+	for scan.Scan() {
+		lnum++
+		if _, err = st.Execute(scan.Text()); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", fname, lnum, err)
+		}
+	}
+	if err = scan.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%d: %s", fname, lnum, err)
+	}
+	events = make(map[string][]*state.Event)
+	for _, e := range st.AllEvents() {
+		station := e.Station()
+		if station == "" {
+			station = "ALL"
+		}
+		key := fmt.Sprintf("%s %s %s", station, e.Type(), e.Name())
+		events[key] = append(events[key], e)
+	}
+	return events, nil
+}
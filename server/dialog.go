@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"html"
+	"slices"
 	"strings"
 	"time"
 
@@ -232,8 +233,21 @@ func (m *Monitor) renderDialog(sb *strings.Builder, eid eventID, e *state.Event)
 		} else if e.Score() != 0 {
 			fmt.Fprintf(sb, `  The message had a transcription score of %d%%.`, e.Score())
 		}
+		if attempts := e.Attempts(); len(attempts) > 1 {
+			fmt.Fprintf(sb, `  It was sent %d times; the local ID and score are for the latest copy.`, len(attempts))
+		}
+		var feedback = m.st.FeedbackFor(e)
+		if len(feedback) != 0 {
+			sb.WriteString(`  Feedback on it was sent to them as `)
+			sb.WriteString(feedback[len(feedback)-1].LMI())
+			sb.WriteByte('.')
+		}
 		m.renderNotes(sb, e)
+		m.renderAttempts(sb, e)
 		m.renderViewButton(sb, "View Message", e.LMI())
+		if len(feedback) != 0 {
+			m.renderViewButton(sb, "View Feedback", feedback[len(feedback)-1].LMI())
+		}
 	case definition.EventSend:
 		sb.WriteString(`Message `)
 		sb.WriteString(html.EscapeString(eid.Name))
@@ -266,6 +280,33 @@ func (m *Monitor) renderDialog(sb *strings.Builder, eid eventID, e *state.Event)
 		} else {
 			m.renderManualTriggerButton(sb, eid.Type, stn.CallSign, eid.Name, "Send Message Now")
 		}
+	case definition.EventSet:
+		var edef = m.def.Event(eid.Type, eid.Name)
+		sb.WriteString(`The variable `)
+		if edef != nil {
+			sb.WriteString(html.EscapeString(edef.Variable))
+		} else {
+			sb.WriteString(html.EscapeString(eid.Name))
+		}
+		if e != nil && !e.Occurred().IsZero() {
+			sb.WriteString(` was set to "`)
+			sb.WriteString(html.EscapeString(e.Value()))
+			sb.WriteString(`" at `)
+			m.renderTime(sb, e.Occurred())
+		} else if e != nil {
+			sb.WriteString(` will be set at `)
+			m.renderTime(sb, e.Expected())
+			m.renderExpectedReason(sb, eid)
+		} else {
+			sb.WriteString(` will be set on request`)
+		}
+		sb.WriteString(` for `)
+		m.renderStation(sb, stn)
+		sb.WriteByte('.')
+		m.renderNotes(sb, e)
+		if e == nil || e.Occurred().IsZero() {
+			m.renderManualTriggerButton(sb, eid.Type, stn.CallSign, eid.Name, "Set Variable Now")
+		}
 	}
 	sb.WriteString(`</div>`)
 }
@@ -313,6 +354,30 @@ func (m *Monitor) renderNotes(sb *strings.Builder, e *state.Event) {
 	}
 }
 
+// renderAttempts renders the history of receipts of a message that was
+// received more than once, in a popup dialog.  The notes for the latest copy
+// are rendered by renderNotes, so only those of the earlier copies are
+// rendered here.
+func (m *Monitor) renderAttempts(sb *strings.Builder, e *state.Event) {
+	var attempts = e.Attempts()
+	if len(attempts) < 2 {
+		return
+	}
+	for i, a := range attempts {
+		fmt.Fprintf(sb, `<div>Copy %d: %s received at `, i+1, a.LMI)
+		m.renderTime(sb, a.Received)
+		if a.Score != 0 {
+			fmt.Fprintf(sb, `, score %d%%`, a.Score)
+		}
+		sb.WriteString(`</div>`)
+		if i < len(attempts)-1 {
+			for _, note := range a.Notes {
+				fmt.Fprintf(sb, "<div>&nbsp;&nbsp;%s</div>", html.EscapeString(note))
+			}
+		}
+	}
+}
+
 // renderViewButton renders a button that opens a message in a separate window.
 // label is the button label.  lmi is the LMI of the message to open.
 func (m *Monitor) renderViewButton(sb *strings.Builder, label, lmi string) {
@@ -324,6 +389,45 @@ func (m *Monitor) renderManualTriggerButton(sb *strings.Builder, etype definitio
 	fmt.Fprintf(sb, `<p><button onclick="javascript:manualTrigger('%s','%s','%s')">%s</button></p>`, etype, station, name, label)
 }
 
+// renderReassignButton renders a button that reassigns the rejected message
+// with the specified LMI to a station and message name.
+func (m *Monitor) renderReassignButton(sb *strings.Builder, lmi, station, name, label string) {
+	fmt.Fprintf(sb, `<p><button onclick="javascript:reassign('%s','%s','%s')">%s</button></p>`, lmi, station, name, label)
+}
+
+// renderReassignForm renders controls for reassigning the rejected message for
+// reject event e to a chosen station and message name.  The station defaults
+// to the one that sent it, if known, and the message name defaults to matching
+// the message as usual, or to the suggested name if there is one.
+func (m *Monitor) renderReassignForm(sb *strings.Builder, e *state.Event) {
+	var names []string
+
+	fmt.Fprintf(sb, `<p>Reassign to <select id="reassign-%s-station">`, e.LMI())
+	if m.def.Station(e.Station()) == nil {
+		sb.WriteString(`<option value="" selected>(station)</option>`)
+	}
+	for _, stn := range m.def.Stations {
+		var selected string
+		if stn.CallSign == e.Station() {
+			selected = " selected"
+		}
+		fmt.Fprintf(sb, `<option%s>%s</option>`, selected, stn.CallSign)
+	}
+	fmt.Fprintf(sb, `</select> <select id="reassign-%s-name"><option value="">(by subject)</option>`, e.LMI())
+	for _, mr := range m.def.MatchReceive {
+		if slices.Contains(names, mr.Name) {
+			continue
+		}
+		names = append(names, mr.Name)
+		var selected string
+		if mr.Name == e.Suggestion() {
+			selected = " selected"
+		}
+		fmt.Fprintf(sb, `<option%s>%s</option>`, selected, html.EscapeString(mr.Name))
+	}
+	fmt.Fprintf(sb, `</select> <button onclick="javascript:reassignSelected('%s')">Reassign</button></p>`, e.LMI())
+}
+
 // renderExpectedReason renders a description of the trigger of an event.  It
 // generally appears immediately after the scheduled or expected time for the
 // event.
@@ -337,38 +441,79 @@ func (m *Monitor) renderExpectedReason(sb *strings.Builder, eid eventID) {
 		return
 	}
 	sb.WriteString(` (`)
-	if e.Delay == 0 {
+	if e.Repeat != nil {
+		// A later instance of a repeating event is triggered by the
+		// previous instance.
+		if ev := m.currentEvent(eid); ev != nil {
+			if prev := m.st.GetEvent(ev.Trigger()); prev != nil && prev.Type() == eid.Type && prev.Name() == eid.Name {
+				m.renderDuration(sb, e.Repeat.Interval)
+				sb.WriteString(` after the previous one)`)
+				return
+			}
+		}
+	}
+	if e.DelayMax != 0 {
+		m.renderDuration(sb, e.Delay)
+		sb.WriteString(` to `)
+		m.renderDuration(sb, e.DelayMax)
+		sb.WriteString(` after `)
+	} else if e.Delay == 0 {
 		sb.WriteString(`on `)
 	} else {
 		m.renderDuration(sb, e.Delay)
 		sb.WriteString(` after `)
 	}
-	switch e.TriggerType {
+	if e.TriggerOverdue {
+		sb.WriteString(`missed deadline for `)
+	}
+	for i, t := range e.TriggerList() {
+		if i != 0 && e.AllTriggers {
+			sb.WriteString(` and `)
+		} else if i != 0 {
+			sb.WriteString(` or `)
+		}
+		m.renderTrigger(sb, e, t.Type, t.Name)
+	}
+	if from := e.TriggerFrom(); from != "" {
+		sb.WriteString(` from `)
+		sb.WriteString(html.EscapeString(from))
+	}
+	sb.WriteByte(')')
+}
+
+// renderTrigger renders a description of one of the triggers of an event.
+func (m *Monitor) renderTrigger(sb *strings.Builder, e *definition.Event, ttype definition.EventType, tname string) {
+	switch ttype {
 	case definition.EventStart:
 		sb.WriteString(`exercise start`)
 	case definition.EventManual:
 		sb.WriteString(`request`)
+	case definition.EventAt:
+		sb.WriteString(`scheduled time `)
+		m.renderTime(sb, e.TriggerTime)
 	case definition.EventAlert:
 		sb.WriteString(`voice alert of transmission of `)
-		sb.WriteString(html.EscapeString(e.TriggerName))
+		sb.WriteString(html.EscapeString(tname))
 	case definition.EventBulletin:
 		sb.WriteString(`posting of bulletin `)
-		sb.WriteString(html.EscapeString(e.TriggerName))
+		sb.WriteString(html.EscapeString(tname))
 	case definition.EventDeliver:
 		sb.WriteString(`delivery to principal of `)
-		sb.WriteString(html.EscapeString(e.TriggerName))
+		sb.WriteString(html.EscapeString(tname))
 	case definition.EventInject:
 		sb.WriteString(`inject of `)
-		sb.WriteString(html.EscapeString(e.TriggerName))
+		sb.WriteString(html.EscapeString(tname))
 	case definition.EventReceipt:
 		sb.WriteString(`getting delivery receipt for `)
-		sb.WriteString(html.EscapeString(e.TriggerName))
+		sb.WriteString(html.EscapeString(tname))
 	case definition.EventReceive:
 		sb.WriteString(`receipt of `)
-		sb.WriteString(html.EscapeString(e.TriggerName))
+		sb.WriteString(html.EscapeString(tname))
 	case definition.EventSend:
 		sb.WriteString(`send of `)
-		sb.WriteString(html.EscapeString(e.TriggerName))
+		sb.WriteString(html.EscapeString(tname))
+	case definition.EventSet:
+		sb.WriteString(`setting of `)
+		sb.WriteString(html.EscapeString(tname))
 	}
-	sb.WriteByte(')')
 }
@@ -27,6 +27,9 @@ type ManualTrigger struct {
 	Type    definition.EventType
 	Station string
 	Name    string
+	// LMI, on a receive trigger, is the LMI of a rejected message to be
+	// reassigned to the station and message name.
+	LMI string
 }
 type eventID struct {
 	Type    definition.EventType
@@ -52,9 +55,10 @@ type Monitor struct {
 	// emap maps from event type and message name to a group number and row
 	// number within that group.
 	emap map[definition.EventType]map[string]gr
-	// events maps from event characteristics to the actual event object for
-	// all events.
-	events map[eventID]*state.Event
+	// events maps from event characteristics to the actual event objects
+	// for all events.  There can be more than one instance of an event if
+	// it repeats or its trigger does.
+	events map[eventID][]*state.Event
 	// unknown is the per-station list of unknown messages received
 	// (actually, the list of "reject" events for same).
 	unknown map[string][]*state.Event
@@ -63,6 +67,12 @@ type Monitor struct {
 	cheads string
 	rheads string
 	grid   string
+	// speed is the speed of the exercise clock, as a multiple of real
+	// time, if it isn't running in real time.
+	speed float64
+	// reloadError is the error from the most recent attempt to reload the
+	// exercise definition, if it failed.
+	reloadError string
 	// mutex controls all access to anything in the structure.
 	mutex sync.Mutex
 }
@@ -81,7 +91,7 @@ func NewMonitor(def *definition.Definition, st *state.State, mtch chan<- ManualT
 		mtch:    mtch,
 		idle:    make(map[*time.Timer]struct{}),
 		conns:   make(map[*websocket.Conn]map[eventID]struct{}),
-		events:  make(map[eventID]*state.Event),
+		events:  make(map[eventID][]*state.Event),
 		unknown: make(map[string][]*state.Event),
 	}
 	// Build the maps and grid.
@@ -233,6 +243,63 @@ func (m *Monitor) OnEventChange(e *state.Event) {
 	}
 }
 
+// OnClockTick receives notification of a clock tick, and wakes up any idle
+// connections so that their clocks are updated.
+func (m *Monitor) OnClockTick() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for timer := range m.idle {
+		timer.Reset(debounceTime)
+		delete(m.idle, timer)
+	}
+}
+
+// SetSpeed tells the monitor that the exercise clock is running at the
+// specified multiple of real time, so that it can show that next to the clock.
+func (m *Monitor) SetSpeed(speed float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.speed = speed
+}
+
+// SetDefinition tells the monitor that the exercise definition has been
+// reloaded.  It rebuilds the maps and grid for the new definition, and closes
+// all websockets so that their clients reload all pertinent data.
+func (m *Monitor) SetDefinition(def *definition.Definition) {
+	var wantReceipts bool
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.def = def
+	m.reloadError = ""
+	wantReceipts = m.buildStationMap()
+	m.buildGroupList()
+	m.buildEventMap(wantReceipts)
+	for conn := range m.conns {
+		delete(m.conns, conn)
+		go conn.Close(websocket.StatusNormalClosure, "exercise definition changed")
+	}
+	// Wake up the idle connection handlers so that they notice their
+	// connections are gone.
+	for timer := range m.idle {
+		timer.Reset(debounceTime)
+		delete(m.idle, timer)
+	}
+}
+
+// SetDefinitionError tells the monitor that an attempt to reload the exercise
+// definition failed, so that it can show the error to its clients.  The monitor
+// continues to use the old definition.
+func (m *Monitor) SetDefinitionError(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.reloadError = "Definition not reloaded: " + err.Error()
+	for timer := range m.idle {
+		timer.Reset(debounceTime)
+		delete(m.idle, timer)
+	}
+}
+
 // saveEvent updates the monitor server's internal cache of events.
 func (m *Monitor) saveEvent(e *state.Event) (eid eventID, ok bool) {
 	// Special case handling for unknown messages.  Add them to the
@@ -244,13 +311,32 @@ func (m *Monitor) saveEvent(e *state.Event) (eid eventID, ok bool) {
 		}
 		return eventID{e.Type(), e.Station(), "UNKNOWN"}, true
 	}
-	// Otherwise, add the event to the map, replacing anything already
-	// there (which ought to be the same event anyway).
+	// Feedback about a received message is shown in the dialog for that
+	// message, so its cell is the one that needs updating.
+	if e.Type() == definition.EventFeedback {
+		return eventID{definition.EventReceive, e.Station(), e.Name()}, true
+	}
+	// Otherwise, add the event to the map, unless it's already there.
 	eid = eventID{e.Type(), e.Station(), e.Name()}
-	m.events[eid] = e
+	if !slices.Contains(m.events[eid], e) {
+		m.events[eid] = append(m.events[eid], e)
+	}
 	return eid, true
 }
 
+// currentEvent returns the instance of an event that is shown in its cell: the
+// earliest one that hasn't occurred yet, or if they all have, the latest one.
+// It returns nil if the event has neither occurred nor been scheduled.
+func (m *Monitor) currentEvent(eid eventID) (current *state.Event) {
+	for _, e := range m.events[eid] {
+		if e.Occurred().IsZero() && !e.Expected().IsZero() {
+			return e
+		}
+		current = e
+	}
+	return current
+}
+
 // ServeHTTP serves the page HTML.
 func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -279,21 +365,35 @@ func (m *Monitor) followEvents(conn *websocket.Conn) {
 	for range timer.C {
 		var buf []byte
 
+		// If the connection was closed because the exercise definition
+		// changed, we're done.
+		m.mutex.Lock()
+		if _, ok := m.conns[conn]; !ok {
+			delete(m.idle, timer)
+			m.mutex.Unlock()
+			return
+		}
 		// Render the data.
 		if first {
 			buf = m.renderInitial()
 			first = false
 		} else {
 			// Figure out what needs to be sent to this client.
-			m.mutex.Lock()
 			tosend := slices.Collect(maps.Keys(m.conns[conn]))
 			clear(m.conns[conn])
-			m.mutex.Unlock()
 			buf = m.renderUpdate(tosend)
 		}
+		m.mutex.Unlock()
 		// Send the data.
 		err := conn.Write(context.Background(), websocket.MessageText, buf)
 		m.mutex.Lock()
+		if _, ok := m.conns[conn]; !ok {
+			// The connection was closed while we were writing to it,
+			// because the exercise definition changed.
+			delete(m.idle, timer)
+			m.mutex.Unlock()
+			return
+		}
 		if err != nil {
 			// Send failed.  Remove the connection from our list.
 			delete(m.conns, conn)
@@ -330,6 +430,9 @@ type updateEntry struct {
 type update struct {
 	// All updates contain the current time of day.
 	Clock string
+	// Error is the error from the most recent attempt to reload the
+	// exercise definition, if it failed.
+	Error string `json:",omitempty"`
 	// Title is the monitor title bar.  Its presence indicates that this is
 	// a first-time update.
 	Title string `json:",omitempty"`
@@ -347,11 +450,20 @@ type update struct {
 	Cells []*updateEntry
 }
 
+// renderClock renders the current time for the monitor header.
+func (m *Monitor) renderClock() string {
+	if m.speed > 1 {
+		return fmt.Sprintf("%s (%gx)", m.st.Now().Format("15:04"), m.speed)
+	}
+	return m.st.Now().Format("15:04")
+}
+
 // renderInitial renders the first-time update.
 func (m *Monitor) renderInitial() (buf []byte) {
 	var update update
 
-	update.Clock = m.st.Now().Format("15:04")
+	update.Clock = m.renderClock()
+	update.Error = m.reloadError
 	update.Title = fmt.Sprintf("%s %s", m.def.Exercise.Activation, m.def.Exercise.Incident)
 	update.RHeads = m.rheads
 	update.CHeads = m.cheads
@@ -372,7 +484,8 @@ func (m *Monitor) renderInitial() (buf []byte) {
 // renderUpdate renders a non-first-time update of the specified cells.
 func (m *Monitor) renderUpdate(cells []eventID) (buf []byte) {
 	var update update
-	update.Clock = m.st.Now().Format("15:04")
+	update.Clock = m.renderClock()
+	update.Error = m.reloadError
 	for _, eid := range cells {
 		if ue := m.renderEvent(eid); ue != nil {
 			update.Cells = append(update.Cells, ue)
@@ -408,7 +521,7 @@ func (m *Monitor) renderEvent(eid eventID) (ue *updateEntry) {
 	} else if gr, ok = emap[eid.Name]; !ok {
 		return nil
 	}
-	e = m.events[eid]
+	e = m.currentEvent(eid)
 	switch {
 	case e == nil:
 		// This event has neither occurred nor been scheduled.  We'll
@@ -440,7 +553,7 @@ func (m *Monitor) renderEvent(eid eventID) (ue *updateEntry) {
 	case e.Occurred().IsZero():
 		sev = "pending"
 		switch e.Type() {
-		case definition.EventBulletin, definition.EventInject, definition.EventSend:
+		case definition.EventBulletin, definition.EventInject, definition.EventSend, definition.EventSet:
 			fmt.Fprintf(&sb, `<svg><use href="#clock"/></svg> at %s`, e.Expected().Format("15:04"))
 		default:
 			fmt.Fprintf(&sb, `<svg><use href="#clock"/></svg> by %s`, e.Expected().Format("15:04"))
@@ -449,6 +562,9 @@ func (m *Monitor) renderEvent(eid eventID) (ue *updateEntry) {
 		sev = "success"
 		fmt.Fprintf(&sb, `<svg><use href="#check"/></svg> at %s`, e.Occurred().Format("15:04"))
 	}
+	if count := len(m.events[eid]); count > 1 {
+		fmt.Fprintf(&sb, `<span class=count>×%d</span>`, count)
+	}
 	m.renderDialog(&sb, eid, e)
 	return &updateEntry{G: gr.g, R: gr.r, C: col, H: sb.String(), S: sev}
 }
@@ -486,10 +602,26 @@ func (m *Monitor) renderUnknownEvents(stn string) (ue *updateEntry) {
 			sb.WriteByte('.')
 		}
 	}
+	var pending bool
 	for _, e := range unk {
 		m.renderViewButton(&sb, "View "+e.LMI(), e.LMI())
+		if tostn, toname := e.ReassignedTo(); tostn != "" {
+			fmt.Fprintf(&sb, `<p>It was reassigned to %s from %s.</p>`, html.EscapeString(toname), html.EscapeString(tostn))
+			continue
+		}
+		pending = true
+		// If it was similar to a known message, offer to process it as
+		// that message.  Either way, offer to process it as any message
+		// from any station.
+		if e.Suggestion() != "" && m.def.Station(stn) != nil {
+			m.renderReassignButton(&sb, e.LMI(), stn, e.Suggestion(), "Assign to "+e.Suggestion())
+		}
+		m.renderReassignForm(&sb, e)
 	}
 	sb.WriteString(`</div>`)
 	ue.H = sb.String()
+	if !pending {
+		ue.S = "success"
+	}
 	return ue
 }
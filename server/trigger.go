@@ -18,7 +18,7 @@ func (m *Monitor) serveManualTrigger(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid type", http.StatusBadRequest)
 		return
 	}
-	mt.Station, mt.Name = r.FormValue("station"), r.FormValue("name")
+	mt.Station, mt.Name, mt.LMI = r.FormValue("station"), r.FormValue("name"), r.FormValue("lmi")
 	if m.mtch != nil {
 		m.mtch <- mt
 	}
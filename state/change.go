@@ -2,6 +2,9 @@ package state
 
 import (
 	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
 	"time"
 
 	"github.com/rothskeller/packet-ex/definition"
@@ -12,9 +15,12 @@ func (s *State) StartExercise() (e *Event) {
 		panic("StartExercise: exercise already started")
 	}
 	s.events = []*Event{nil} // put a nil in [0] so that EIDs start with 1.
+	if s.seed == 0 {
+		s.seed = rand.Int64N(math.MaxInt64) + 1
+	}
 	return s.mustExecutef(
-		"%s [%d] ALL start",
-		s.logNow(), len(s.events))
+		"%s [%d] ALL start SEED %d",
+		s.logNow(), len(s.events), s.seed)
 }
 
 func (s *State) StartStation(station string) (e *Event) {
@@ -23,16 +29,24 @@ func (s *State) StartStation(station string) (e *Event) {
 		s.logNow(), len(s.events), station)
 }
 
-func (s *State) SendMessage(etype definition.EventType, station, name, lmi, subject string, trigger int) (e *Event) {
+// ReachTriggerTime records that the time of an "at" trigger has been reached,
+// for the specified station (or globally, if station is empty).  name is the
+// trigger time as written in the exercise definition.
+func (s *State) ReachTriggerTime(station, name string) (e *Event) {
+	return s.mustExecutef(
+		"%s [%d] %s at %s",
+		s.logNow(), len(s.events), safeStation(station), name)
+}
+
+// SendMessage records the sending of a message.  triggers are the IDs of the
+// events that triggered it, as for ScheduleEvent.
+func (s *State) SendMessage(etype definition.EventType, station, name, lmi, subject string, triggers ...int) (e *Event) {
 	eid := len(s.events)
-	if ev := s.FindEvent(etype, station, name); ev != nil {
+	if ev := s.currentEvent(etype, station, name); ev != nil {
 		eid = ev.id
 	}
-	line := fmt.Sprintf("%s [%d] %s %s %s SENT LMI %s",
-		s.logNow(), eid, safeStation(station), etype, name, lmi)
-	if trigger != 0 {
-		line = fmt.Sprintf("%s [%d]", line, trigger)
-	}
+	line := fmt.Sprintf("%s [%d] %s %s %s SENT LMI %s%s",
+		s.logNow(), eid, safeStation(station), etype, name, lmi, triggerSuffix(triggers))
 	e = s.mustExecute(line)
 	if subject != "" {
 		s.mustExecute("    Subject: " + subject)
@@ -40,6 +54,19 @@ func (s *State) SendMessage(etype definition.EventType, station, name, lmi, subj
 	return e
 }
 
+// SendFeedback records the sending of a feedback message to a station about a
+// message received from them.  receive is the receive event for that message.
+// Each copy of a resent message gets its own feedback, so feedback events are
+// never reused.
+func (s *State) SendFeedback(receive *Event, lmi, subject string) (e *Event) {
+	e = s.mustExecutef("%s [%d] %s feedback %s SENT LMI %s [%d]",
+		s.logNow(), len(s.events), receive.station, receive.name, lmi, receive.id)
+	if subject != "" {
+		s.mustExecute("    Subject: " + subject)
+	}
+	return e
+}
+
 func (s *State) CreateInject(station, name, rmi, method string, trigger int) (e *Event) {
 	switch method {
 	case "PRINTED", "EMAILED", "CREATED":
@@ -48,7 +75,7 @@ func (s *State) CreateInject(station, name, rmi, method string, trigger int) (e
 		panic("invalid inject method")
 	}
 	eid := len(s.events)
-	if ev := s.FindEvent(definition.EventInject, station, name); ev != nil {
+	if ev := s.currentEvent(definition.EventInject, station, name); ev != nil {
 		eid = ev.id
 	}
 	line := fmt.Sprintf("%s [%d] %s inject %s %s",
@@ -63,8 +90,19 @@ func (s *State) CreateInject(station, name, rmi, method string, trigger int) (e
 }
 
 func (s *State) MatchInject(station, name, rmi string) (e *Event) {
-	ev := s.FindEvent(definition.EventInject, station, name)
-	if ev == nil || (ev.rmi != "" && ev.rmi != rmi) {
+	var ev *Event
+	// If the inject has happened more than once, match the instance with
+	// the same RMI, or failing that, the earliest one with no RMI.
+	for _, inst := range s.FindEvents(definition.EventInject, station, name) {
+		if inst.rmi == rmi {
+			ev = inst
+			break
+		}
+		if ev == nil && inst.rmi == "" && !inst.occurred.IsZero() {
+			ev = inst
+		}
+	}
+	if ev == nil {
 		return nil
 	}
 	line := fmt.Sprintf("%s [%d] %s inject %s MATCHED RMI %s",
@@ -72,24 +110,52 @@ func (s *State) MatchInject(station, name, rmi string) (e *Event) {
 	return s.mustExecute(line)
 }
 
-func (s *State) RecordReject(station, name, lmi, from, subject string) (e *Event) {
+// RecordReject records the rejection of a received message.  suggest, if not
+// empty, is a similar message name that the message may have been meant to be.
+func (s *State) RecordReject(station, name, lmi, from, subject, suggest string) (e *Event) {
 	line := fmt.Sprintf("%s [%d] %s reject %s REJECTED LMI %s",
 		s.logNow(), len(s.events), station, name, lmi)
 	if from != "" && from != s.addrs[station] {
 		line = fmt.Sprintf("%s FROM %s", line, from)
 	}
+	if suggest != "" {
+		line = fmt.Sprintf("%s SUGGEST %s", line, suggest)
+	}
 	e = s.mustExecute(line)
 	s.mustExecute("    Subject: " + subject)
 	return e
 }
 
-func (s *State) ReceiveMessage(station, name, lmi, from, subject string) (e *Event) {
+// ReassignReject records that the rejected message for the reject event e is
+// to be processed as the named message from the specified station.
+func (s *State) ReassignReject(e *Event, station, name string) *Event {
+	return s.mustExecutef("%s [%d] %s reject %s REASSIGNED TO %s %s",
+		s.logNow(), e.id, e.station, e.name, station, name)
+}
+
+func (s *State) ReceiveMessage(station, name, lmi, rmi, from, subject string) (e *Event) {
 	eid := len(s.events)
-	if ev := s.FindEvent(definition.EventReceive, station, name); ev != nil && ev.Occurred().IsZero() {
+	if ev := s.currentEvent(definition.EventReceive, station, name); ev != nil && ev.Occurred().IsZero() {
 		eid = ev.id
 	}
 	line := fmt.Sprintf("%s [%d] %s receive %s RECEIVED LMI %s",
 		s.logNow(), eid, station, name, lmi)
+	return s.receiveLine(line, station, rmi, from, subject)
+}
+
+// ResendMessage records the receipt of another copy of the message for the
+// receive event e, which has already been received.
+func (s *State) ResendMessage(e *Event, lmi, rmi, from, subject string) *Event {
+	line := fmt.Sprintf("%s [%d] %s receive %s RESENT LMI %s",
+		s.logNow(), e.id, e.station, e.name, lmi)
+	return s.receiveLine(line, e.station, rmi, from, subject)
+}
+
+// receiveLine finishes and records a RECEIVED or RESENT line.
+func (s *State) receiveLine(line, station, rmi, from, subject string) (e *Event) {
+	if rmi != "" {
+		line = fmt.Sprintf("%s RMI %s", line, rmi)
+	}
 	if from != "" && from != s.addrs[station] {
 		line = fmt.Sprintf("%s FROM %s", line, from)
 	}
@@ -123,30 +189,81 @@ func (s *State) ReceiveDeliveryReceipt(lmi, rmi string) (e *Event) {
 	return nil
 }
 
-func (s *State) ScheduleEvent(etype definition.EventType, station, name string, at time.Time, trigger int) (e *Event) {
+// SetVariable records the setting of a variable by a set event.  triggers are
+// the IDs of the events that triggered it, ending with the one that set it off;
+// there can be several if the event waited for all of them.  (The same is true
+// of ScheduleEvent and ExpectEvent.)
+func (s *State) SetVariable(station, name, vname, value string, triggers ...int) (e *Event) {
+	eid := len(s.events)
+	if ev := s.currentEvent(definition.EventSet, station, name); ev != nil && ev.occurred.IsZero() {
+		eid = ev.id
+	} else if ev != nil && !s.isNewTrigger(ev, triggers) {
+		return nil
+	}
+	line := fmt.Sprintf("%s [%d] %s set %s SET %s %s",
+		s.logNow(), eid, station, name, vname, strconv.Quote(value))
+	return s.mustExecute(line + triggerSuffix(triggers))
+}
+
+func (s *State) ScheduleEvent(etype definition.EventType, station, name string, at time.Time, triggers ...int) (e *Event) {
 	switch etype {
-	case definition.EventBulletin, definition.EventSend, definition.EventInject:
+	case definition.EventBulletin, definition.EventSend, definition.EventInject, definition.EventSet:
 		break
 	default:
 		panic("invalid etype for scheduled event")
 	}
 	eid := len(s.events)
-	if ev := s.FindEvent(etype, station, name); ev != nil {
-		if !ev.occurred.IsZero() {
-			return nil
-		}
+	if ev := s.currentEvent(etype, station, name); ev != nil && ev.occurred.IsZero() {
 		eid = ev.id
+	} else if ev != nil && !s.isNewTrigger(ev, triggers) {
+		return nil
 	}
 	line := fmt.Sprintf("%s [%d] %s %s %s SCHEDULED %s",
 		s.logNow(), eid, safeStation(station), etype, name,
 		at.Format(expectedFormat))
-	if trigger != 0 {
-		line = fmt.Sprintf("%s [%d]", line, trigger)
+	return s.mustExecute(line + triggerSuffix(triggers))
+}
+
+// isNewTrigger returns whether the last of triggers is a different occurrence
+// of an event's trigger than any that have already caused an instance of it.
+// This happens when the triggering event repeats.
+func (s *State) isNewTrigger(ev *Event, triggers []int) bool {
+	if len(triggers) == 0 || triggers[len(triggers)-1] == 0 {
+		return false
 	}
-	return s.mustExecute(line)
+	return s.GetEventByTrigger(ev.etype, ev.station, ev.name, triggers[len(triggers)-1]) == nil
+}
+
+// RepeatEvent creates the next instance of a repeating event, scheduled or
+// expected at the specified time.  The new instance records the previous one
+// as its trigger.
+func (s *State) RepeatEvent(prev *Event, at time.Time) (e *Event) {
+	var (
+		eid  = len(s.events)
+		verb string
+	)
+	switch prev.etype {
+	case definition.EventBulletin, definition.EventSend, definition.EventInject, definition.EventSet:
+		verb = "SCHEDULED"
+	case definition.EventAlert, definition.EventDeliver:
+		verb = "EXPECTED"
+	case definition.EventReceive:
+		verb = "EXPECTED"
+		// It's possible the message was already received before it was
+		// expected.
+		if e = s.FindEvent(prev.etype, prev.station, prev.name); e != nil && e.lmi != "" && e.expected.IsZero() {
+			eid = e.id
+		}
+	default:
+		panic("invalid etype for repeated event")
+	}
+	return s.mustExecutef(
+		"%s [%d] %s %s %s %s %s [%d]",
+		s.logNow(), eid, safeStation(prev.station), prev.etype, prev.name,
+		verb, at.Format(expectedFormat), prev.id)
 }
 
-func (s *State) ExpectEvent(etype definition.EventType, station, name string, by time.Time, trigger int) (e *Event) {
+func (s *State) ExpectEvent(etype definition.EventType, station, name string, by time.Time, triggers ...int) (e *Event) {
 	var eid = len(s.events)
 
 	switch etype {
@@ -162,9 +279,9 @@ func (s *State) ExpectEvent(etype definition.EventType, station, name string, by
 		panic("invalid etype for expected event")
 	}
 	return s.mustExecutef(
-		"%s [%d] %s %s %s EXPECTED %s [%d]",
+		"%s [%d] %s %s %s EXPECTED %s%s",
 		s.logNow(), eid, safeStation(station), etype, name,
-		by.Format(expectedFormat), trigger)
+		by.Format(expectedFormat), triggerSuffix(triggers))
 }
 
 func (s *State) RecordEvent(etype definition.EventType, station, name string) (e *Event) {
@@ -175,7 +292,7 @@ func (s *State) RecordEvent(etype definition.EventType, station, name string) (e
 		panic("invalid etype for recorded event")
 	}
 	eid := len(s.events)
-	if ev := s.FindEvent(etype, station, name); ev != nil {
+	if ev := s.currentEvent(etype, station, name); ev != nil {
 		if !ev.occurred.IsZero() {
 			return nil
 		}
@@ -186,7 +303,10 @@ func (s *State) RecordEvent(etype definition.EventType, station, name string) (e
 		s.logNow(), eid, safeStation(station), etype, name)
 }
 
-func (s *State) MarkOverdueEvents(asof time.Time) {
+// MarkOverdueEvents marks as overdue any expected events whose expected time
+// is before asof and that haven't happened yet.  It returns the events that it
+// marked.
+func (s *State) MarkOverdueEvents(asof time.Time) (marked []*Event) {
 	for _, e := range s.events {
 		if e == nil {
 			continue
@@ -201,12 +321,13 @@ func (s *State) MarkOverdueEvents(asof time.Time) {
 			continue
 		}
 		if e.expected.Before(asof) {
-			s.mustExecutef(
+			marked = append(marked, s.mustExecutef(
 				"%s [%d] %s %s %s OVERDUE",
 				s.logNow(), e.id, safeStation(e.station), e.etype, e.name,
-			)
+			))
 		}
 	}
+	return marked
 }
 
 func (s *State) DropEvent(e *Event) {
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"strings"
 	"time"
 
 	"github.com/rothskeller/packet-ex/definition"
@@ -13,13 +14,37 @@ type Event struct {
 	etype    definition.EventType
 	name     string
 	trigger  int
+	joined   []int
 	expected time.Time
 	occurred time.Time
 	overdue  bool
 	lmi      string
 	rmi      string
 	score    int
-	notes    []string
+	value    string
+	attempts []Attempt
+	// suggest is the similar message name suggested for a rejected
+	// message, and toStation and toName are the station and message name
+	// it was reassigned to, if it was.
+	suggest   string
+	toStation string
+	toName    string
+	notes     []string
+}
+
+// An Attempt is one receipt of the message for a receive event.  A message can
+// be received more than once, if the sender resends it.
+type Attempt struct {
+	// LMI is the local message ID of this copy of the message.
+	LMI string
+	// Received is the time at which this copy of the message was received.
+	Received time.Time
+	// Score is the percentage score for this copy of the message.  It is
+	// zero if it hasn't been analyzed.
+	Score int
+	// Notes are the notes recorded with this copy of the message, such as
+	// its subject line and the problems found in it.
+	Notes []string
 }
 
 // ID is the unique identifier of the event.
@@ -52,6 +77,17 @@ func (e *Event) Trigger() int {
 	return e.trigger
 }
 
+// Triggers returns the unique identifiers of all of the events that triggered
+// this one.  For an event that waited for several triggers, these are all of
+// them, ending with Trigger (the last to happen); for any other event, it is
+// just Trigger.  It is empty if there is no such event.
+func (e *Event) Triggers() []int {
+	if e.trigger == 0 {
+		return nil
+	}
+	return append(e.joined[:len(e.joined):len(e.joined)], e.trigger)
+}
+
 // Expected is the time at which this event is scheduled or by which this event
 // is expected.  It is zero if Trigger is zero.
 func (e *Event) Expected() time.Time {
@@ -70,7 +106,8 @@ func (e *Event) Overdue() bool {
 }
 
 // LMI is the local message ID of the message for a "send" or "receive" event
-// that has occurred.  It is empty for other events.
+// that has occurred.  (For a message received more than once, it is the LMI of
+// the latest copy.)  It is empty for other events.
 func (e *Event) LMI() string {
 	return e.lmi
 }
@@ -83,13 +120,55 @@ func (e *Event) RMI() string {
 }
 
 // Score is the percentage score (between 0 and 100) for a received message.  It
-// is zero for all other events.
+// is zero for all other events.  If the message was received more than once, it
+// is the score of the latest copy.
 func (e *Event) Score() int {
 	return e.score
 }
 
-// Notes are the notes associated with the event, if any.  The returned slice
-// should not be changed by the caller.
+// Attempts returns the history of receipts of the message for a receive event,
+// in the order they were received.  There is more than one if the sender
+// resent the message.  The returned slice should not be changed by the caller.
+func (e *Event) Attempts() []Attempt {
+	return e.attempts
+}
+
+// Subject returns the subject line of the message for a send or receive event
+// (the latest copy, if it was received more than once), or an empty string if
+// it wasn't recorded.
+func (e *Event) Subject() (subject string) {
+	for _, note := range e.notes {
+		if s, ok := strings.CutPrefix(note, "Subject: "); ok {
+			subject = s
+		}
+	}
+	return subject
+}
+
+// Suggestion is the message name that a rejected message was similar to,
+// suggested as the one it was meant to be.  It is empty if there was no
+// similar message name, and for all other events.
+func (e *Event) Suggestion() string {
+	return e.suggest
+}
+
+// ReassignedTo returns the station and message name that a rejected message
+// was reassigned to, and processed as, by the exercise manager.  They are empty
+// if it hasn't been reassigned, and for all other events.
+func (e *Event) ReassignedTo() (station, name string) {
+	return e.toStation, e.toName
+}
+
+// Value is the value assigned to the variable by a "set" event that has
+// occurred.  It is empty for all other events.
+func (e *Event) Value() string {
+	return e.value
+}
+
+// Notes are the notes associated with the event, if any.  For a message
+// received more than once, they include only the notes for the latest copy; the
+// notes for each copy are in its Attempt.  The returned slice should not be
+// changed by the caller.
 func (e *Event) Notes() []string {
 	if len(e.notes) == 0 {
 		return nil
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResentMessageNotes(t *testing.T) {
+	var st = New(false)
+
+	for _, line := range []string{
+		"2023-09-23T09:00:00.001 [1] ALL start SEED 1",
+		"2023-09-23T09:00:00.002 [2] XND001 start",
+		"2023-09-23T09:00:00.003 [3] XND001 receive CheckIn EXPECTED 2023-09-23T09:15 [2]",
+		"2023-09-23T09:04:00.002 [3] XND001 receive CheckIn RECEIVED LMI XND-100P RMI XNB-101P FROM xnd001@w5xsc.ampr.org",
+		"    Subject: XNB-101P_R_Check-In",
+		"2023-09-23T09:04:00.003 [3] XND001 receive CheckIn SCORE 83",
+		"    PROBLEM: wrong message number prefix",
+		"2023-09-23T09:08:00.002 [3] XND001 receive CheckIn RESENT LMI XND-101P RMI XNA-101P",
+		"    Subject: XNA-101P_R_Check-In",
+		"2023-09-23T09:08:00.003 [3] XND001 receive CheckIn SCORE 100",
+	} {
+		if _, err := st.Execute(line); err != nil {
+			t.Fatalf("%s: %s", line, err)
+		}
+	}
+	e := st.GetEvent(3)
+	if want := []string{"Subject: XNA-101P_R_Check-In"}; !slices.Equal(e.Notes(), want) {
+		t.Errorf("Notes() = %q, want %q", e.Notes(), want)
+	}
+	if e.Subject() != "XNA-101P_R_Check-In" || e.Score() != 100 || e.LMI() != "XND-101P" {
+		t.Errorf("latest copy: subject %q, score %d, LMI %s", e.Subject(), e.Score(), e.LMI())
+	}
+	attempts := e.Attempts()
+	if len(attempts) != 2 {
+		t.Fatalf("got %d attempts, want 2", len(attempts))
+	}
+	if want := []string{"Subject: XNB-101P_R_Check-In", "PROBLEM: wrong message number prefix"}; !slices.Equal(attempts[0].Notes, want) {
+		t.Errorf("first copy notes = %q, want %q", attempts[0].Notes, want)
+	}
+	if attempts[0].Score != 83 || attempts[1].Score != 100 {
+		t.Errorf("copy scores = %d, %d; want 83, 100", attempts[0].Score, attempts[1].Score)
+	}
+	if want := e.Notes(); !slices.Equal(attempts[1].Notes, want) {
+		t.Errorf("latest copy notes = %q, want %q", attempts[1].Notes, want)
+	}
+}
@@ -12,9 +12,10 @@ import (
 	"github.com/rothskeller/packet-ex/definition"
 )
 
-var stateLineRE = regexp.MustCompile(`^(20\d\d-(?:0[1-9]|1[0-2])-(?:0[1-9]|[12][0-9]|3[01])T(?:[01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9]\.[0-9]{3}) \[(\d+)\] ([A-Z][A-Z0-9]{0,5}) (\S+)`)
-var errWarnLineRE = regexp.MustCompile(`^(20\d\d-(?:0[1-9]|1[0-2])-(?:0[1-9]|[12][0-9]|3[01])T(?:[01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9]\.[0-9]{3}) (?:ERROR|WARNING): `)
-var triggerRE = regexp.MustCompile(`^\[(\d+)\]$`)
+var stateLineRE = regexp.MustCompile(`^(20\d\d-(?:0[1-9]|1[0-2])-(?:0[1-9]|[12][0-9]|3[01])T(?:[01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9]\.[0-9]{3}) \[(\d+)\] ([A-Z][A-Z0-9]*) (\S+)`)
+var errWarnLineRE = regexp.MustCompile(`^(20\d\d-(?:0[1-9]|1[0-2])-(?:0[1-9]|[12][0-9]|3[01])T(?:[01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9]\.[0-9]{3}) (?:ERROR: |WARNING: |DEFINITION RELOADED\s*$)`)
+var triggerRE = regexp.MustCompile(`^\[(\d+(?:,\d+)*)\]$`)
+var setRE = regexp.MustCompile(`^SET ((?:exercise|station)\.\S+) ("(?:[^"\\]|\\.)*")`)
 
 // Execute parses and executes a single state change line.
 func (s *State) Execute(line string) (e *Event, err error) {
@@ -32,7 +33,7 @@ func (s *State) Execute(line string) (e *Event, err error) {
 	if s.debug {
 		fmt.Println(line)
 	}
-	// Ignore blank lines, errors, and warnings.
+	// Ignore blank lines, errors, warnings, and definition reloads.
 	if strings.TrimSpace(line) == "" || errWarnLineRE.MatchString(line) {
 		s.lastEID = 0
 		return nil, nil
@@ -42,6 +43,9 @@ func (s *State) Execute(line string) (e *Event, err error) {
 		if s.lastEID != 0 {
 			e = s.events[s.lastEID]
 			e.notes = append(e.notes, strings.TrimSpace(line))
+			if n := len(e.attempts); n != 0 {
+				e.attempts[n-1].Notes = append(e.attempts[n-1].Notes, strings.TrimSpace(line))
+			}
 			goto DONE
 		}
 		return nil, nil
@@ -83,10 +87,13 @@ func (s *State) Execute(line string) (e *Event, err error) {
 		e = s.events[e.id]
 	case e.id == len(s.events):
 		// New event.  Make sure there are no existing events with the
-		// same characteristics.  (EventReceive and EventReject are
-		// exceptions.)
-		if e.etype != definition.EventReject && e.etype != definition.EventReceive && slices.ContainsFunc(s.events, func(ee *Event) bool {
-			return ee != nil && ee.etype == e.etype && ee.station == e.station && ee.name == e.name
+		// same characteristics and trigger.  (EventReceive,
+		// EventReject, and EventFeedback are exceptions.)  An event can
+		// have more than one instance, with different triggers, if it
+		// repeats or its trigger does.
+		trigger := lineTrigger(line)
+		if e.etype != definition.EventReject && e.etype != definition.EventReceive && e.etype != definition.EventFeedback && slices.ContainsFunc(s.events, func(ee *Event) bool {
+			return ee != nil && ee.etype == e.etype && ee.station == e.station && ee.name == e.name && ee.trigger == trigger
 		}) {
 			return nil, errors.New("creating redundant event")
 		}
@@ -101,15 +108,33 @@ func (s *State) Execute(line string) (e *Event, err error) {
 	s.lastTime, s.lastEID = tstamp, e.id
 	// Now look for the various other things that can appear on the line.
 	fields = strings.Fields(line)
-	// A start event has no arguments; if it's seen, it occurred.
-	if e.etype == definition.EventStart && len(fields) == 0 {
+	// The exercise start event carries the random seed for the exercise.
+	if e.etype == definition.EventStart && e.station == "" && len(fields) == 2 && fields[0] == "SEED" {
+		if s.seed, err = strconv.ParseInt(fields[1], 10, 64); err != nil || s.seed == 0 {
+			return nil, errors.New("syntax error: bad seed")
+		}
+		fields = nil
+	}
+	// A start or at event has no arguments; if it's seen, it occurred.
+	if (e.etype == definition.EventStart || e.etype == definition.EventAt) && len(fields) == 0 {
 		e.occurred = tstamp
 		goto DONE
 	}
 	// If the last one is a number in brackets, it's the trigger event ID.
+	// If it's a comma-separated list of numbers, the event waited for
+	// several triggers, and the last one is the one that set it off.
 	if match := triggerRE.FindStringSubmatch(fields[len(fields)-1]); match != nil {
-		if e.trigger, _ = strconv.Atoi(match[1]); e.trigger < 1 || e.trigger >= len(s.events) {
-			return nil, errors.New("invalid trigger ID")
+		var ids []int
+		for _, idstr := range strings.Split(match[1], ",") {
+			if id, _ := strconv.Atoi(idstr); id < 1 || id >= len(s.events) {
+				return nil, errors.New("invalid trigger ID")
+			} else {
+				ids = append(ids, id)
+			}
+		}
+		e.trigger = ids[len(ids)-1]
+		if len(ids) > 1 {
+			e.joined = ids[:len(ids)-1]
 		}
 		fields = fields[:len(fields)-1]
 	}
@@ -123,6 +148,28 @@ func (s *State) Execute(line string) (e *Event, err error) {
 		e.expected = time.Time{}
 		goto DONE
 	}
+	// If a set is followed by SET, a variable name, and a quoted value, it
+	// has occurred, and the variable has that value.  (The value is parsed
+	// from the line rather than the fields since it can contain spaces.)
+	if e.etype == definition.EventSet && len(fields) >= 3 && fields[0] == "SET" {
+		var match = setRE.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			return nil, errors.New("syntax error in set")
+		}
+		if !e.occurred.IsZero() {
+			return nil, errors.New("variable re-set")
+		}
+		if e.value, err = strconv.Unquote(match[2]); err != nil {
+			return nil, errors.New("syntax error in set value")
+		}
+		e.occurred = tstamp
+		if strings.HasPrefix(match[1], "exercise.") {
+			s.variables[variableKey{"", match[1]}] = e.value
+		} else {
+			s.variables[variableKey{e.station, match[1]}] = e.value
+		}
+		goto DONE
+	}
 	// "PRINTED", "EMAILED", and "CREATED" all indicate occurrence of an
 	// inject, and may all be followed by an RMI.
 	if e.etype == definition.EventInject && (len(fields) == 1 || (len(fields) == 3 && fields[1] == "RMI")) && (fields[0] == "PRINTED" || fields[0] == "EMAILED" || fields[0] == "CREATED") {
@@ -147,9 +194,9 @@ func (s *State) Execute(line string) (e *Event, err error) {
 		e.rmi = fields[2]
 		goto DONE
 	}
-	// If a bulletin or send is followed by SENT and an LMI, that means it
-	// occurred.
-	if (e.etype == definition.EventBulletin || e.etype == definition.EventSend) && len(fields) == 3 && fields[0] == "SENT" && fields[1] == "LMI" {
+	// If a bulletin, send, or feedback is followed by SENT and an LMI, that
+	// means it occurred.
+	if (e.etype == definition.EventBulletin || e.etype == definition.EventSend || e.etype == definition.EventFeedback) && len(fields) == 3 && fields[0] == "SENT" && fields[1] == "LMI" {
 		if !e.occurred.IsZero() {
 			return nil, errors.New("message re-sent")
 		}
@@ -157,29 +204,62 @@ func (s *State) Execute(line string) (e *Event, err error) {
 		e.occurred = tstamp
 		goto DONE
 	}
-	// If a reject is followed by REJECTED, an LMI, and possibly a FROM,
-	// it has occurred.
-	if e.etype == definition.EventReject && (len(fields) == 3 || len(fields) == 5) && fields[0] == "REJECTED" && fields[1] == "LMI" && (len(fields) == 3 || fields[3] == "FROM") {
+	// If a reject is followed by REJECTED, an LMI, and possibly a FROM
+	// and a SUGGEST, it has occurred.
+	if e.etype == definition.EventReject && len(fields) >= 3 && fields[0] == "REJECTED" && fields[1] == "LMI" {
 		e.lmi = fields[2]
+		for fields = fields[3:]; len(fields) != 0; fields = fields[2:] {
+			switch {
+			case len(fields) >= 2 && fields[0] == "FROM":
+				// for human readers only
+			case len(fields) >= 2 && fields[0] == "SUGGEST":
+				e.suggest = fields[1]
+			default:
+				return nil, errors.New("syntax error: unknown entry format")
+			}
+		}
 		e.occurred = tstamp
 		goto DONE
 	}
-	// If a receive is followed by RECEIVED, an LMI, and possibly a FROM,
-	// we record its details.  If it was expected, we also mark it as having
-	// occurred.
-	if e.etype == definition.EventReceive && (len(fields) == 3 || len(fields) == 5) && fields[0] == "RECEIVED" && fields[1] == "LMI" && (len(fields) == 3 || fields[3] == "FROM") {
+	// If a reject is followed by REASSIGNED TO, a station, and a message
+	// name, the rejected message was reassigned to them.
+	if e.etype == definition.EventReject && len(fields) == 4 && fields[0] == "REASSIGNED" && fields[1] == "TO" {
+		if e.occurred.IsZero() {
+			return nil, errors.New("reassignment of unrejected message")
+		}
+		if e.toStation != "" {
+			return nil, errors.New("message re-reassigned")
+		}
+		e.toStation, e.toName = fields[2], fields[3]
+		goto DONE
+	}
+	// If a receive is followed by RECEIVED, an LMI, and possibly an RMI
+	// and a FROM, we record its details.  If it was expected, we also mark
+	// it as having occurred.
+	if e.etype == definition.EventReceive && len(fields) != 0 && fields[0] == "RECEIVED" {
 		if !e.occurred.IsZero() {
 			return nil, errors.New("message re-received")
 		}
-		e.lmi = fields[2]
-		if len(fields) == 5 {
-			s.addrs[e.station] = fields[4]
+		if err = s.receiveAttempt(e, fields[1:], tstamp); err != nil {
+			return nil, err
 		}
 		if !e.expected.IsZero() {
 			e.occurred = tstamp
 		}
 		goto DONE
 	}
+	// If a receive is followed by RESENT, an LMI, and possibly an RMI and
+	// a FROM, the message was received again.  We record the details of
+	// the new copy, keeping the history of the earlier ones.
+	if e.etype == definition.EventReceive && len(fields) != 0 && fields[0] == "RESENT" {
+		if e.lmi == "" {
+			return nil, errors.New("resend of unreceived message")
+		}
+		if err = s.receiveAttempt(e, fields[1:], tstamp); err != nil {
+			return nil, err
+		}
+		goto DONE
+	}
 	// If a receive is followed by a SCORE, that means it was analyzed.
 	if e.etype == definition.EventReceive && len(fields) == 2 && fields[0] == "SCORE" {
 		if e.lmi == "" {
@@ -188,6 +268,9 @@ func (s *State) Execute(line string) (e *Event, err error) {
 		if e.score, err = strconv.Atoi(fields[1]); err != nil || e.score < 0 || e.score > 100 {
 			return nil, errors.New("invalid score")
 		}
+		if len(e.attempts) != 0 {
+			e.attempts[len(e.attempts)-1].Score = e.score
+		}
 		goto DONE
 	}
 	// If a send is followed by "DELIVERED" and an RMI, we add the RMI to
@@ -207,7 +290,7 @@ func (s *State) Execute(line string) (e *Event, err error) {
 	}
 	// Handle the various expect cases.
 	switch e.etype {
-	case definition.EventBulletin, definition.EventSend, definition.EventInject:
+	case definition.EventBulletin, definition.EventSend, definition.EventInject, definition.EventSet:
 		if len(fields) == 2 && fields[0] == "SCHEDULED" {
 			if !e.occurred.IsZero() {
 				return nil, errors.New("rescheduling completed event")
@@ -260,3 +343,63 @@ DONE:
 	}
 	return e, nil
 }
+
+// receiveAttempt records the receipt of a copy of the message for a receive
+// event.  fields are the fields of the state line following RECEIVED or
+// RESENT: an LMI, and possibly an RMI and a FROM.
+func (s *State) receiveAttempt(e *Event, fields []string, tstamp time.Time) error {
+	if len(fields) < 2 || fields[0] != "LMI" {
+		return errors.New("syntax error: missing LMI")
+	}
+	var lmi = fields[1]
+	for fields = fields[2:]; len(fields) != 0; fields = fields[2:] {
+		switch {
+		case len(fields) >= 2 && fields[0] == "RMI":
+			e.rmi = fields[1]
+		case len(fields) >= 2 && fields[0] == "FROM":
+			s.addrs[e.station] = fields[1]
+		default:
+			return errors.New("syntax error: unknown entry format")
+		}
+	}
+	// A message that came in before it was expected is recorded again,
+	// with the same LMI, when it's expected.  That's not a new copy.
+	if len(e.attempts) == 0 || e.attempts[len(e.attempts)-1].LMI != lmi {
+		// The event's notes are for the latest copy, so the notes of
+		// the previous copy (which end the list) are dropped from them.
+		// That copy keeps them in its Attempt.
+		if n := len(e.attempts); n != 0 {
+			e.notes = slices.Clip(e.notes[:len(e.notes)-len(e.attempts[n-1].Notes)])
+		}
+		e.attempts = append(e.attempts, Attempt{LMI: lmi, Received: tstamp})
+	}
+	e.lmi = lmi
+	return nil
+}
+
+// lineTrigger returns the triggering event ID given at the end of a state line
+// (the last one, if there are several), or zero if there is none.
+func lineTrigger(line string) (trigger int) {
+	if fields := strings.Fields(line); len(fields) != 0 {
+		if match := triggerRE.FindStringSubmatch(fields[len(fields)-1]); match != nil {
+			ids := strings.Split(match[1], ",")
+			trigger, _ = strconv.Atoi(ids[len(ids)-1])
+		}
+	}
+	return trigger
+}
+
+// triggerSuffix returns the suffix of a state line giving the IDs of the
+// events that triggered it, or an empty string if there are none.
+func triggerSuffix(triggers []int) string {
+	var ids []string
+	for _, id := range triggers {
+		if id != 0 {
+			ids = append(ids, strconv.Itoa(id))
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(ids, ",") + "]"
+}
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"slices"
 	"strings"
 
 	"github.com/rothskeller/packet-ex/definition"
@@ -36,6 +37,32 @@ func (s *State) FindEvent(etype definition.EventType, station, name string) *Eve
 	return nil
 }
 
+// FindEvents returns all instances of the event with the specified type,
+// station, and message name, in the order they were created.  There can be
+// more than one if the event repeats or its trigger happened more than once.
+func (s *State) FindEvents(etype definition.EventType, station, name string) (evs []*Event) {
+	for _, ev := range s.events {
+		if ev != nil && ev.etype == etype && ev.station == station && ev.name == name {
+			evs = append(evs, ev)
+		}
+	}
+	return evs
+}
+
+// currentEvent returns the instance of the event with the specified type,
+// station, and message name that changes to it should apply to: the earliest
+// one that hasn't occurred yet, or if they all have, the latest one.  It
+// returns nil if there are no instances of the event.
+func (s *State) currentEvent(etype definition.EventType, station, name string) (current *Event) {
+	for _, ev := range s.FindEvents(etype, station, name) {
+		if ev.occurred.IsZero() {
+			return ev
+		}
+		current = ev
+	}
+	return current
+}
+
 // GetEventByTrigger returns any existing event with the specified type,
 // station, message name, and trigger.
 func (s *State) GetEventByTrigger(etype definition.EventType, station, name string, trigger int) *Event {
@@ -48,18 +75,72 @@ func (s *State) GetEventByTrigger(etype definition.EventType, station, name stri
 }
 
 // GetSendReceiveEventByStationName returns the Send or Receive event with the specified
-// station and message name.
+// station and message name.  If there is more than one, it returns the latest
+// one whose message has been sent or received.
 func (s *State) GetSendReceiveEventByStationName(station, name string) *Event {
 	for i := len(s.events) - 1; i > 0; i-- {
 		e := s.events[i]
 		if (e.etype == definition.EventReceive || e.etype == definition.EventSend) &&
-			e.station == station && e.name == name {
+			e.station == station && e.name == name && e.lmi != "" {
 			return e
 		}
 	}
 	return nil
 }
 
+// FindResentMessage returns the receive event for which a message just received
+// from the station, with the specified message name, origin message number
+// (rmi), and subject line, is a resend, or nil if it isn't one.  It's a resend
+// if it has the same origin message number as a message already received.  It's
+// also a resend if it has the same subject line as one, unless the station has
+// another instance of the message outstanding (as happens when a receive event
+// repeats).
+func (s *State) FindResentMessage(station, name, rmi, subject string) *Event {
+	var received []*Event
+	var outstanding bool
+
+	for _, e := range s.FindEvents(definition.EventReceive, station, name) {
+		if e.lmi != "" {
+			received = append(received, e)
+		} else if !e.expected.IsZero() {
+			outstanding = true
+		}
+	}
+	if rmi != "" {
+		if idx := slices.IndexFunc(received, func(e *Event) bool { return e.rmi == rmi }); idx >= 0 {
+			return received[idx]
+		}
+	}
+	if subject != "" && !outstanding {
+		if idx := slices.IndexFunc(received, func(e *Event) bool { return e.Subject() == subject }); idx >= 0 {
+			return received[idx]
+		}
+	}
+	return nil
+}
+
+// FindReject returns the reject event for the rejected message with the
+// specified LMI, or nil if there is none.
+func (s *State) FindReject(lmi string) *Event {
+	if idx := slices.IndexFunc(s.events, func(e *Event) bool {
+		return e != nil && e.etype == definition.EventReject && e.lmi == lmi
+	}); idx >= 0 {
+		return s.events[idx]
+	}
+	return nil
+}
+
+// FeedbackFor returns the feedback events for the receive event e, one for each
+// copy of its message that feedback was sent about.
+func (s *State) FeedbackFor(e *Event) (evs []*Event) {
+	for _, fe := range s.FindEvents(definition.EventFeedback, e.station, e.name) {
+		if fe.trigger == e.id {
+			evs = append(evs, fe)
+		}
+	}
+	return evs
+}
+
 // StationStarted returns whether the specified station has started the
 // exercise.
 func (s *State) StationStarted(stn string) bool {
@@ -116,9 +197,20 @@ func (s *State) PendingEvent(etype definition.EventType) (event *Event) {
 // IsMessageExpected returns whether a received message with the specified
 // station and message name is expected.
 func (s *State) IsMessageExpected(station, name string) bool {
-	if ev := s.FindEvent(definition.EventReceive, station, name); ev == nil {
+	if ev := s.currentEvent(definition.EventReceive, station, name); ev == nil {
 		return false
 	} else {
 		return ev.occurred.IsZero() && !ev.expected.IsZero()
 	}
 }
+
+// Variable returns the value most recently assigned to the specified variable
+// by a set event, or false if it hasn't been set.  The station is ignored for
+// exercise.XXX variables.
+func (s *State) Variable(station, vname string) (value string, ok bool) {
+	if strings.HasPrefix(vname, "exercise.") {
+		station = ""
+	}
+	value, ok = s.variables[variableKey{station, vname}]
+	return value, ok
+}
@@ -32,16 +32,25 @@ type EventListener interface {
 type State struct {
 	events    []*Event
 	addrs     map[string]string
+	variables map[variableKey]string
 	listeners []any
 	now       func() time.Time
 	lastTime  time.Time
 	lastEID   int
+	seed      int64
 	debug     bool
 }
 
 // New creates a new State tracker.
 func New(debug bool) *State {
-	return &State{now: time.Now, debug: debug, addrs: make(map[string]string)}
+	return &State{now: time.Now, debug: debug, addrs: make(map[string]string), variables: make(map[variableKey]string)}
+}
+
+// A variableKey identifies a variable that has been set by a set event.  The
+// station is empty for exercise.XXX variables.
+type variableKey struct {
+	station string
+	name    string
 }
 
 // SetNowFunc sets the function used by the state engine to determine the
@@ -50,6 +59,20 @@ func (s *State) SetNowFunc(now func() time.Time) {
 	s.now = now
 }
 
+// SetSeed sets the random seed to be recorded when the exercise starts, in
+// place of a randomly chosen one.  It is used for replaying old exercises and
+// for testing.
+func (s *State) SetSeed(seed int64) {
+	s.seed = seed
+}
+
+// Seed returns the random seed for the exercise.  Random delays and message
+// variants are chosen based on it, so that they come out the same when the
+// engine is restarted or the exercise is replayed.
+func (s *State) Seed() int64 {
+	return s.seed
+}
+
 // Now returns the current time of day.
 func (s *State) Now() time.Time {
 	return s.now()
@@ -74,6 +97,12 @@ func (s *State) LogError(err error) {
 	s.mustExecutef("%s ERROR: %s\n", s.logNow(), err)
 }
 
+// LogDefinitionReloaded adds a note to the log file that the exercise
+// definition was reloaded.
+func (s *State) LogDefinitionReloaded() {
+	s.mustExecutef("%s DEFINITION RELOADED\n", s.logNow())
+}
+
 // mustExecute records a state change entry in the state log, and then
 // executes it as if reading it from the log.
 func (s *State) mustExecutef(f string, args ...any) *Event {